@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheFilePath is where the SRD catalog is kept between runs.
+var CacheFilePath = defaultCachePath()
+
+// CacheTTL is how long a synced catalog is used before it is fetched again.
+var CacheTTL = 30 * 24 * time.Hour
+
+// Catalog is the part of the SRD fetched from the API so far, as returned by
+// the API. Spells are added as classes look them up; FetchedAt is set when
+// sync-catalog downloaded everything.
+type Catalog struct {
+	FetchedAt time.Time                 `json:"fetched_at"`
+	Spells    []APISpell                `json:"spells"`
+	Classes   map[string]ClassSpellList `json:"classes,omitempty"`
+}
+
+// ClassSpellList is the spell list of one class, keyed in the catalog by
+// lowercase class name.
+type ClassSpellList struct {
+	FetchedAt time.Time     `json:"fetched_at"`
+	Spells    []APIResource `json:"spells"`
+}
+
+// needsRefresh reports whether a part of the catalog fetched at fetchedAt
+// should be fetched again: when it was never fetched, or when it is older
+// than CacheTTL and the API wasn't found unreachable a moment ago.
+func needsRefresh(fetchedAt time.Time) bool {
+	if fetchedAt.IsZero() {
+		return true
+	}
+	return time.Since(fetchedAt) > CacheTTL && time.Since(refreshFailed) >= refreshRetryDelay
+}
+
+func (c *Catalog) spell(name string) (APISpell, bool) {
+	for _, spell := range c.Spells {
+		if strings.EqualFold(spell.Name, name) {
+			return spell, true
+		}
+	}
+	return APISpell{}, false
+}
+
+// refreshRetryDelay keeps a stale catalog in use for a while after a failed
+// refresh, instead of trying the API again for every lookup.
+const refreshRetryDelay = 10 * time.Minute
+
+var (
+	catalogMu     sync.Mutex
+	catalog       *Catalog
+	refreshFailed time.Time
+)
+
+func defaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "srd-catalog.json"
+	}
+	return filepath.Join(dir, "dnd-character-sheet", "srd-catalog.json")
+}
+
+// ClassSpells returns the spells of a class at the given levels. Only the
+// class's spell list and the details of spells not cached yet are fetched;
+// both are added to the cache. A stale list is still used offline.
+//
+// The catalog is only locked to read and update it, not while fetching, so
+// lookups of cached spells don't wait for a slow download.
+func ClassSpells(ctx context.Context, className string, levels map[int]bool) ([]APISpell, error) {
+	class := strings.ToLower(className)
+	list, err := classSpellList(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogMu.Lock()
+	var spells []APISpell
+	var missing []APIResource
+	for _, ref := range list.Spells {
+		if !levels[ref.Level] {
+			continue
+		}
+		if spell, ok := catalog.spell(ref.Name); ok {
+			spells = append(spells, spell)
+		} else {
+			missing = append(missing, ref)
+		}
+	}
+	catalogMu.Unlock()
+
+	if len(missing) > 0 {
+		fetched, err := DefaultClient.FetchSpells(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		catalogMu.Lock()
+		for _, spell := range fetched {
+			// Another lookup may have fetched the same spell meanwhile.
+			if _, ok := catalog.spell(spell.Name); !ok {
+				catalog.Spells = append(catalog.Spells, spell)
+			}
+		}
+		saveCache()
+		catalogMu.Unlock()
+		spells = append(spells, fetched...)
+	}
+	return spells, nil
+}
+
+// classSpellList returns the cached spell list of class, fetching it first
+// when it is missing or stale.
+func classSpellList(ctx context.Context, class string) (ClassSpellList, error) {
+	catalogMu.Lock()
+	if !loadCache() {
+		catalog = &Catalog{}
+	}
+	list := catalog.Classes[class]
+	refresh := needsRefresh(list.FetchedAt)
+	catalogMu.Unlock()
+	if !refresh {
+		return list, nil
+	}
+
+	refs, err := DefaultClient.FetchClassSpells(ctx, class)
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	switch {
+	case err == nil:
+		list = ClassSpellList{FetchedAt: time.Now(), Spells: refs}
+		if catalog.Classes == nil {
+			catalog.Classes = map[string]ClassSpellList{}
+		}
+		catalog.Classes[class] = list
+		saveCache()
+	case list.FetchedAt.IsZero():
+		return list, fmt.Errorf("fetching %s spell list: %w", class, err)
+	default:
+		refreshFailed = time.Now()
+		log.Printf("Using %s spell list from %s, refreshing it failed: %v", class, list.FetchedAt.Format(time.DateOnly), err)
+	}
+	return list, nil
+}
+
+// saveCache writes the catalog to the cache file. The caller holds catalogMu.
+func saveCache() {
+	if err := writeCache(catalog); err != nil {
+		log.Println("Error saving catalog cache:", err)
+	}
+}
+
+// CachedSpell looks a spell up in the cache without going online.
+func CachedSpell(name string) (APISpell, bool) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	if !loadCache() {
+		return APISpell{}, false
+	}
+	return catalog.spell(name)
+}
+
+// loadCache reads the cache file the first time it is needed and reports
+// whether there is a catalog.
+func loadCache() bool {
+	if catalog == nil {
+		cached, err := readCache()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Println("Ignoring unreadable catalog cache:", err)
+		}
+		catalog = cached
+	}
+	return catalog != nil
+}
+
+// SyncCatalog fetches the whole catalog with DefaultClient and replaces the
+// cache. The existing cache is kept when any item fails to download.
+func SyncCatalog(ctx context.Context) (*Catalog, error) {
+	fresh, err := DefaultClient.FetchCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	if err := writeCache(fresh); err != nil {
+		return nil, fmt.Errorf("saving catalog cache: %w", err)
+	}
+	catalog = fresh
+	return catalog, nil
+}
+
+func readCache() (*Catalog, error) {
+	data, err := os.ReadFile(CacheFilePath)
+	if err != nil {
+		return nil, err
+	}
+	var cached Catalog
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+// writeCache replaces the cache file in one step, so an interrupted sync
+// never leaves half a catalog behind.
+func writeCache(c *Catalog) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(CacheFilePath), 0755); err != nil {
+		return err
+	}
+	tmp := CacheFilePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, CacheFilePath)
+}
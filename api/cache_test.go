@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTestCatalog starts from an empty catalog cached in a temporary
+// directory, fetched from srv.
+func useTestCatalog(t *testing.T, srv *httptest.Server) {
+	t.Helper()
+	client, path := DefaultClient, CacheFilePath
+	t.Cleanup(func() {
+		DefaultClient, CacheFilePath, catalog = client, path, nil
+	})
+	DefaultClient = testClient(srv)
+	CacheFilePath = filepath.Join(t.TempDir(), "srd-catalog.json")
+	catalog = &Catalog{Spells: []APISpell{{Name: "Light", Level: 0}}}
+}
+
+func TestCachedSpellDoesNotWaitForFetches(t *testing.T) {
+	requested, release := make(chan bool, 1), make(chan bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/classes/wizard/spells" {
+			requested <- true
+			<-release
+			fmt.Fprint(w, `{"results": [{"index": "light", "name": "Light", "level": 0, "url": "/api/spells/light"}]}`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	useTestCatalog(t, srv)
+
+	done := make(chan error)
+	go func() {
+		_, err := ClassSpells(context.Background(), "Wizard", map[int]bool{0: true})
+		done <- err
+	}()
+	<-requested
+
+	found := make(chan bool)
+	go func() {
+		_, ok := CachedSpell("light")
+		found <- ok
+	}()
+	select {
+	case ok := <-found:
+		if !ok {
+			t.Error("Light isn't cached")
+		}
+	case <-time.After(time.Second):
+		t.Error("CachedSpell waited for the spell list to download")
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := CachedSpell("light"); !ok {
+		t.Error("Light was lost from the cache")
+	}
+}
+
+func TestClassSpellsCachesFetchedSpells(t *testing.T) {
+	var detailRequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/classes/wizard/spells":
+			fmt.Fprint(w, `{"results": [
+				{"index": "light", "name": "Light", "level": 0, "url": "/api/spells/light"},
+				{"index": "shield", "name": "Shield", "level": 1, "url": "/api/spells/shield"}]}`)
+		case "/api/spells/shield":
+			detailRequests++
+			fmt.Fprint(w, `{"name": "Shield", "level": 1}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	useTestCatalog(t, srv)
+
+	for i := 0; i < 2; i++ {
+		spells, err := ClassSpells(context.Background(), "wizard", map[int]bool{0: true, 1: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spells) != 2 {
+			t.Errorf("lookup %d found %d spells, want 2", i+1, len(spells))
+		}
+	}
+	if detailRequests != 1 {
+		t.Errorf("Shield was fetched %d times, want once", detailRequests)
+	}
+	catalog = nil
+	if _, ok := CachedSpell("Shield"); !ok {
+		t.Error("Shield wasn't written to the cache file")
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the public dnd5eapi.co instance.
+const DefaultBaseURL = "https://www.dnd5eapi.co"
+
+// Client talks to a dnd5eapi server: the public one or a self-hosted
+// 5e-database mirror.
+type Client struct {
+	// BaseURL is the scheme and host the API paths are appended to.
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+	// MaxRetries is how often a request is repeated after a 429, a 5xx or a
+	// network error, waiting Backoff and then twice as long each time.
+	MaxRetries int
+	Backoff    time.Duration
+	// Workers is how many requests of a catalog download run at once.
+	Workers int
+	// RequestInterval paces the requests of a catalog download across all
+	// workers so the public API isn't flooded. Zero doesn't pace them.
+	RequestInterval time.Duration
+	// Progress, when set, is called as the items of a catalog download
+	// complete, with the kind of resource being fetched.
+	Progress func(resource string, done, total int)
+}
+
+// DefaultClient is used by the catalog functions of this package.
+var DefaultClient = NewClient(DefaultBaseURL)
+
+// NewClient returns a client for the API at baseURL with the settings the
+// public API is comfortable with.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:         strings.TrimRight(baseURL, "/"),
+		HTTPClient:      &http.Client{Timeout: 30 * time.Second},
+		UserAgent:       "dnd-character-sheet",
+		MaxRetries:      3,
+		Backoff:         time.Second,
+		Workers:         4,
+		RequestInterval: 200 * time.Millisecond,
+	}
+}
+
+// statusError is an unexpected HTTP status from the API.
+type statusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.URL, e.StatusCode, e.Body)
+}
+
+func (e *statusError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// getJSON decodes the resource at path into target, retrying failures that
+// are likely to pass.
+func (c *Client) getJSON(ctx context.Context, path string, target interface{}) error {
+	url := c.BaseURL + strings.ToLower(strings.ReplaceAll(path, " ", "-"))
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err := c.get(ctx, url, target)
+		if err == nil || attempt >= c.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		wait := backoff
+		var status *statusError
+		if errors.As(err, &status) {
+			if !status.temporary() {
+				return err
+			}
+			if status.RetryAfter > wait {
+				wait = status.RetryAfter
+			}
+		}
+		log.Printf("retrying %s in %s: %v", url, wait, err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) get(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := &statusError{URL: url, StatusCode: resp.StatusCode, Body: string(body[:min(100, len(body))])}
+		if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil {
+			err.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return err
+	}
+
+	if !json.Valid(body) {
+		return fmt.Errorf("invalid JSON from %s: %s", url, string(body[:min(100, len(body))]))
+	}
+	return json.Unmarshal(body, target)
+}
+
+// FetchCatalog downloads every spell and the spell list of every class.
+func (c *Client) FetchCatalog(ctx context.Context) (*Catalog, error) {
+	spells, err := fetchAll[APISpell](ctx, c, "spells", "/api/spells")
+	if err != nil {
+		return nil, fmt.Errorf("fetching spells: %w", err)
+	}
+
+	var classes APIListResponse
+	if err := c.getJSON(ctx, "/api/classes", &classes); err != nil {
+		return nil, fmt.Errorf("fetching classes: %w", err)
+	}
+	spellLists := make([]APIResource, len(classes.Results))
+	for i, class := range classes.Results {
+		spellLists[i] = APIResource{Name: class.Name, URL: class.URL + "/spells"}
+	}
+	lists, err := fetchItems[APIListResponse](ctx, c, "class spell lists", spellLists)
+	if err != nil {
+		return nil, fmt.Errorf("fetching class spell lists: %w", err)
+	}
+
+	now := time.Now()
+	catalog := &Catalog{
+		FetchedAt: now,
+		Spells:    spells,
+		Classes:   map[string]ClassSpellList{},
+	}
+	for i, class := range classes.Results {
+		catalog.Classes[strings.ToLower(class.Name)] = ClassSpellList{FetchedAt: now, Spells: lists[i].Results}
+	}
+	return catalog, nil
+}
+
+// FetchClassSpells returns the spell list of a class, with the level of
+// each spell but not its details.
+func (c *Client) FetchClassSpells(ctx context.Context, className string) ([]APIResource, error) {
+	var list APIListResponse
+	if err := c.getJSON(ctx, "/api/classes/"+className+"/spells", &list); err != nil {
+		return nil, err
+	}
+	return list.Results, nil
+}
+
+// FetchSpells downloads the details of the given spells.
+func (c *Client) FetchSpells(ctx context.Context, spells []APIResource) ([]APISpell, error) {
+	return fetchItems[APISpell](ctx, c, "spells", spells)
+}
+
+// fetchAll gets the list at path and then each item on it.
+func fetchAll[T any](ctx context.Context, c *Client, resource, path string) ([]T, error) {
+	var list APIListResponse
+	if err := c.getJSON(ctx, path, &list); err != nil {
+		return nil, err
+	}
+	return fetchItems[T](ctx, c, resource, list.Results)
+}
+
+// fetchItems gets each of refs with a pool of c.Workers workers. Any item
+// that can't be fetched fails the whole download; the error lists every item
+// that failed.
+func fetchItems[T any](ctx context.Context, c *Client, resource string, refs []APIResource) ([]T, error) {
+	total := len(refs)
+	items := make([]T, total)
+	errs := make([]error, total)
+	jobs := make(chan int)
+
+	var mu sync.Mutex
+	done := 0
+	var wg sync.WaitGroup
+	for range max(c.Workers, 1) {
+		wg.Go(func() {
+			for i := range jobs {
+				res := refs[i]
+				if err := c.getJSON(ctx, res.URL, &items[i]); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", res.Name, err)
+				}
+				mu.Lock()
+				done++
+				if c.Progress != nil {
+					c.Progress(resource, done, total)
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+	var pace <-chan time.Time
+	if c.RequestInterval > 0 {
+		ticker := time.NewTicker(c.RequestInterval)
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+	// The first request goes out right away; the ticker paces the rest.
+	for i := range refs {
+		if i > 0 && pace != nil {
+			select {
+			case <-ctx.Done():
+			case <-pace:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("%d of %d %s could not be fetched:\n%w", len(failed), total, resource, errors.Join(failed...))
+	}
+	return items, nil
+}
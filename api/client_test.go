@@ -0,0 +1,259 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client for srv that retries without waiting long.
+func testClient(srv *httptest.Server) *Client {
+	c := NewClient(srv.URL)
+	c.Backoff = time.Millisecond
+	c.RequestInterval = 0
+	return c
+}
+
+func TestClientRetriesTemporaryErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"results": [{"name": "Wizard", "url": "/api/classes/wizard"}]}`)
+	}))
+	defer srv.Close()
+
+	var list APIListResponse
+	if err := testClient(srv).getJSON(context.Background(), "/api/classes", &list); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 3 || len(list.Results) != 1 {
+		t.Errorf("%d requests, %d results; want 3 requests and 1 result", requests.Load(), len(list.Results))
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	for _, test := range []struct {
+		status       int
+		wantRequests int32
+	}{
+		{http.StatusInternalServerError, 4},
+		{http.StatusTooManyRequests, 4},
+		{http.StatusNotFound, 1},
+	} {
+		var requests atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			http.Error(w, "nope", test.status)
+		}))
+
+		var list APIListResponse
+		err := testClient(srv).getJSON(context.Background(), "/api/classes", &list)
+		var status *statusError
+		if !errors.As(err, &status) || status.StatusCode != test.status {
+			t.Errorf("status %d: error %v", test.status, err)
+		}
+		if requests.Load() != test.wantRequests {
+			t.Errorf("status %d: %d requests, want %d", test.status, requests.Load(), test.wantRequests)
+		}
+		srv.Close()
+	}
+}
+
+func TestClientHonorsRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"results": []}`)
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	var list APIListResponse
+	if err := testClient(srv).getJSON(context.Background(), "/api/spells", &list); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least the 1s of Retry-After", elapsed)
+	}
+}
+
+func TestClientStopsWhenCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := testClient(srv)
+	c.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var list APIListResponse
+	err := c.getJSON(ctx, "/api/spells", &list)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want the context's", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %s to give up", elapsed)
+	}
+}
+
+func TestClientRequest(t *testing.T) {
+	var path, userAgent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, userAgent = r.URL.Path, r.UserAgent()
+		fmt.Fprint(w, `{"results": []}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL + "/")
+	c.UserAgent = "test-agent"
+	if _, err := c.FetchClassSpells(context.Background(), "Wizard"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/classes/wizard/spells" {
+		t.Errorf("path %q", path)
+	}
+	if userAgent != "test-agent" {
+		t.Errorf("User-Agent %q", userAgent)
+	}
+}
+
+func TestClientRejectsInvalidJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>maintenance</html>")
+	}))
+	defer srv.Close()
+
+	var list APIListResponse
+	err := testClient(srv).getJSON(context.Background(), "/api/spells", &list)
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("error %v, want invalid JSON", err)
+	}
+}
+
+// spellServer serves a list of spells at /api/spells whose details are at
+// /api/spells/<index>, except those in missing, and counts the requests that
+// run at the same time.
+type spellServer struct {
+	names   []string
+	missing map[string]bool
+
+	mu             sync.Mutex
+	running, peak  int
+	detailRequests int
+}
+
+func (s *spellServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.running++
+	s.peak = max(s.peak, s.running)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	if r.URL.Path == "/api/spells" {
+		var results []string
+		for _, name := range s.names {
+			results = append(results, fmt.Sprintf(`{"name": %q, "url": "/api/spells/%s"}`, name, strings.ToLower(name)))
+		}
+		fmt.Fprintf(w, `{"results": [%s]}`, strings.Join(results, ","))
+		return
+	}
+	index := strings.TrimPrefix(r.URL.Path, "/api/spells/")
+	s.mu.Lock()
+	s.detailRequests++
+	s.mu.Unlock()
+	if s.missing[index] {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintf(w, `{"name": %q, "level": 1}`, index)
+}
+
+func TestFetchAllUsesWorkers(t *testing.T) {
+	spells := &spellServer{names: []string{"a", "b", "c", "d", "e", "f", "g", "h"}}
+	srv := httptest.NewServer(spells)
+	defer srv.Close()
+
+	c := testClient(srv)
+	c.Workers = 3
+	var progress []int
+	c.Progress = func(resource string, done, total int) {
+		if resource != "spells" || total != 8 {
+			t.Errorf("progress %s %d/%d", resource, done, total)
+		}
+		progress = append(progress, done)
+	}
+
+	got, err := fetchAll[APISpell](context.Background(), c, "spells", "/api/spells")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, spell := range got {
+		if spell.Name != spells.names[i] {
+			t.Errorf("spell %d is %q, want %q", i, spell.Name, spells.names[i])
+		}
+	}
+	if spells.peak < 2 || spells.peak > 3 {
+		t.Errorf("%d requests ran at once, want 2 or 3 with 3 workers", spells.peak)
+	}
+	if len(progress) != 8 || progress[7] != 8 {
+		t.Errorf("progress %v", progress)
+	}
+}
+
+func TestFetchAllReportsEveryFailure(t *testing.T) {
+	spells := &spellServer{names: []string{"a", "b", "c", "d"}, missing: map[string]bool{"b": true, "d": true}}
+	srv := httptest.NewServer(spells)
+	defer srv.Close()
+
+	_, err := fetchAll[APISpell](context.Background(), testClient(srv), "spells", "/api/spells")
+	if err == nil {
+		t.Fatal("no error for missing spells")
+	}
+	for _, want := range []string{"2 of 4 spells", "b:", "d:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+	if spells.detailRequests != 4 {
+		t.Errorf("%d detail requests, want every spell fetched once", spells.detailRequests)
+	}
+}
+
+func TestFetchAllPacesRequests(t *testing.T) {
+	spells := &spellServer{names: []string{"a", "b", "c", "d", "e"}}
+	srv := httptest.NewServer(spells)
+	defer srv.Close()
+
+	c := testClient(srv)
+	c.Workers = 5
+	c.RequestInterval = 20 * time.Millisecond
+	start := time.Now()
+	if _, err := fetchAll[APISpell](context.Background(), c, "spells", "/api/spells"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 paced requests took %s, want at least 4 intervals", elapsed)
+	}
+}
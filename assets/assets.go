@@ -0,0 +1,21 @@
+// Package assets embeds the web templates, static files and SRD catalog data
+// so the binaries run from any directory.
+package assets
+
+import "embed"
+
+// Templates holds templates/*.html.
+//
+//go:embed templates/*.html
+var Templates embed.FS
+
+// Static holds the files served under /static/.
+//
+//go:embed static
+var Static embed.FS
+
+// Data holds the spell and equipment catalogs, data/spells.csv and
+// data/equipment.csv.
+//
+//go:embed data/*.csv
+var Data embed.FS
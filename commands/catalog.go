@@ -0,0 +1,17 @@
+package commands
+
+import (
+	"dnd-character-sheet/assets"
+	"fmt"
+)
+
+// LoadCatalog loads the spell and equipment catalogs embedded in the binary.
+func LoadCatalog() error {
+	if err := LoadSpellsFromCSV(assets.Data, "data/spells.csv"); err != nil {
+		return fmt.Errorf("failed to load spells: %w", err)
+	}
+	if err := LoadEquipmentCSV(assets.Data, "data/equipment.csv"); err != nil {
+		return fmt.Errorf("failed to load equipment: %w", err)
+	}
+	return nil
+}
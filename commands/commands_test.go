@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dnd-character-sheet/api"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+func TestMain(m *testing.M) {
+	if err := LoadCatalog(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// useTempStorage keeps characters, history and the SRD catalog cache in a
+// temporary directory for the rest of the test. The SRD API is unreachable.
+func useTempStorage(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	storage.Configure(filepath.Join(dir, "characters.json"))
+	api.DefaultClient = api.NewClient("http://127.0.0.1:1")
+	api.DefaultClient.MaxRetries = 0
+	api.CacheFilePath = filepath.Join(dir, "srd-catalog.json")
+}
+
+// newCaster returns a character of class and level with every ability score
+// at score and its spell slots set up.
+func newCaster(class string, level, score int) models.Character {
+	c := models.Character{
+		Name:      "Test " + class,
+		Class:     class,
+		Level:     level,
+		Abilities: models.AbilityScores{Strength: score, Dexterity: score, Constitution: score, Intelligence: score, Wisdom: score, Charisma: score},
+	}
+	SetupSpellcasting(&c)
+	return c
+}
+
+// classSpells returns count spells of the class at level from the spell list.
+func classSpells(t *testing.T, class string, level, count int) []models.Spell {
+	t.Helper()
+	var spells []models.Spell
+	for _, spell := range FindSpellsForClass(class) {
+		if spell.Level == level && len(spells) < count {
+			spells = append(spells, spell)
+		}
+	}
+	if len(spells) < count {
+		t.Fatalf("the spell list has only %d level %d %s spells", len(spells), level, class)
+	}
+	return spells
+}
+
+func saveTestCharacter(t *testing.T, c models.Character) {
+	t.Helper()
+	if err := storage.SaveCharacter(c); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,7 +3,6 @@ package commands
 import (
 	"dnd-character-sheet/models"
 	"dnd-character-sheet/storage"
-	"errors"
 	"fmt"
 )
 
@@ -16,6 +15,7 @@ func CreateCharacter(
 	characterLevel int,
 	abilityScores []int,
 	skillProficiencies []string,
+	skillExpertise []string,
 ) error {
 	existingCharacters, err := storage.LoadCharacters()
 	if err != nil {
@@ -23,13 +23,18 @@ func CreateCharacter(
 	}
 
 	if _, exists := existingCharacters[characterName]; exists {
-		return errors.New("character met deze naam bestaat al")
+		return fmt.Errorf("%w: %s", storage.ErrCharacterExists, characterName)
 	}
 
 	if len(abilityScores) != 6 {
 		abilityScores = nil
 	}
 
+	for i, skill := range skillProficiencies {
+		if name, ok := models.NormalizeSkillName(skill); ok {
+			skillProficiencies[i] = name
+		}
+	}
 
 	if len(skillProficiencies) == 0 {
 		availableSkills := models.GetAvailableSkills(characterClass)
@@ -40,14 +45,17 @@ func CreateCharacter(
 				skillSet[s] = true
 				uniqueSkills = append(uniqueSkills, s)
 			}
-			if len(uniqueSkills) == 4 { 
+			if len(uniqueSkills) == 4 {
 				break
 			}
 		}
 		skillProficiencies = uniqueSkills
 	}
 
-	newCharacterID := len(existingCharacters) + 1
+	newCharacterID, err := storage.GetNextCharacterID()
+	if err != nil {
+		return fmt.Errorf("failed to assign character ID: %w", err)
+	}
 
 	newCharacter := models.NewCharacter(
 		newCharacterID,
@@ -60,11 +68,22 @@ func CreateCharacter(
 		skillProficiencies,
 	)
 
-	newCharacter.CanPrepareSpells = PreparedCasters[characterClass]
+	newCharacter.PlayerName = playerName
 
-	if err := GiveStartingSpells(newCharacter); err != nil {
-		return fmt.Errorf("failed to give starting spells: %w", err)
+	expertise, err := normalizeSkills(skillExpertise)
+	if err != nil {
+		return err
 	}
+	newCharacter.SkillExpertise = expertise
+	if err := newCharacter.ValidateExpertise(); err != nil {
+		return invalid(err)
+	}
+	newCharacter.CalculateAllSkills()
+	newCharacter.CalculateCombatStats()
+
+	newCharacter.CanPrepareSpells = PreparedCasters[characterClass]
+
+	GiveStartingSpells(newCharacter)
 
 	if err := storage.SaveCharacter(*newCharacter); err != nil {
 		return fmt.Errorf("failed to save character: %w", err)
@@ -72,3 +91,29 @@ func CreateCharacter(
 
 	return nil
 }
+
+// AddCharacter stores a character filled in elsewhere, such as on the web
+// form, as a new character. It gets an ID, its derived stats and spell slots
+// are calculated, it is validated and given its starting spells.
+func AddCharacter(character models.Character) error {
+	existingCharacters, err := storage.LoadCharacters()
+	if err != nil {
+		return fmt.Errorf("failed to load characters: %w", err)
+	}
+	if _, exists := existingCharacters[character.Name]; exists {
+		return fmt.Errorf("%w: %s", storage.ErrCharacterExists, character.Name)
+	}
+
+	if character.ID, err = storage.GetNextCharacterID(); err != nil {
+		return fmt.Errorf("failed to assign character ID: %w", err)
+	}
+	if err := normalizeCharacter(&character); err != nil {
+		return err
+	}
+	GiveStartingSpells(&character)
+
+	if err := storage.SaveCharacter(character); err != nil {
+		return fmt.Errorf("failed to save character: %w", err)
+	}
+	return nil
+}
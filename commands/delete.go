@@ -2,24 +2,16 @@ package commands
 
 import (
 	"dnd-character-sheet/storage"
+	"errors"
 	"fmt"
 )
 
 func DeleteCharacter(characterName string) error {
-	allCharacters, err := storage.LoadCharacters()
-	if err != nil {
-		return err
-	}
-
-	for key, character := range allCharacters {
-		if character.Name == characterName {
-			delete(allCharacters, key)
-			if err := storage.SaveAllCharacters(allCharacters); err != nil {
-				return fmt.Errorf("failed to delete character: %w", err)
-			}
-			return nil
+	if err := storage.DeleteCharacter(characterName); err != nil {
+		if errors.Is(err, storage.ErrCharacterNotFound) {
+			return fmt.Errorf("%w: %s", err, characterName)
 		}
+		return fmt.Errorf("failed to delete character: %w", err)
 	}
-
-	return fmt.Errorf("character not found: %s", characterName)
+	return nil
 }
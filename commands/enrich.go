@@ -1,49 +1,249 @@
 package commands
 
 import (
+	"context"
 	"dnd-character-sheet/api"
+	"dnd-character-sheet/models"
 	"dnd-character-sheet/storage"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
 )
 
-func EnrichCharacter(name string) error {
+// EnrichOptions steers what EnrichCharacter picks.
+type EnrichOptions struct {
+	// EquipmentOption is the 1-based number of the loadout in
+	// StartingEquipmentOptions; 0 takes the first one.
+	EquipmentOption int
+	// Rand makes the spell pick repeatable; nil picks differently each time.
+	Rand *rand.Rand
+	// PinSpells are always picked and ExcludeSpells never.
+	PinSpells     []string
+	ExcludeSpells []string
+}
+
+// EnrichCharacter fills in the spells the character is still missing from
+// the SRD and equips starting equipment of its class in the slots it has
+// left empty. Spells and equipment the character already has are kept, and
+// a class without starting equipment only gets spells.
+func EnrichCharacter(ctx context.Context, name string, opts EnrichOptions) error {
 	char, err := storage.GetCharacterByName(name)
 	if err != nil {
 		return fmt.Errorf("failed to load character: %w", err)
 	}
+	before := char.Clone()
 
-	if char.Level > 0 && len(char.SpellSlots) > 0 {
-		spells, err := api.GetSpellsForClass(char.Class, char.SpellSlots)
-		if err != nil {
-			log.Println("failed to get spells:", err)
-		} else {
-			char.Spells = spells
-		}
+	options, err := StartingEquipmentOptions(char)
+	if err != nil && (!errors.Is(err, ErrNoStartingEquipment) || opts.EquipmentOption > 0) {
+		return err
+	}
+	if err != nil {
+		fmt.Printf("Skipping equipment: %v\n", err)
+	}
+	option := opts.EquipmentOption
+	if option < 0 || option > len(options) {
+		return fmt.Errorf("no starting equipment option %d, choose 1 to %d", option, len(options))
+	}
+	if option == 0 && len(options) > 0 {
+		option = 1
 	}
 
-	mainHand, offHand, armor, shield, err := api.GetEquipment()
+	added, err := pickSpells(ctx, &char, opts)
 	if err != nil {
-		log.Println("failed to get equipment:", err)
-	} else {
-		if mainHand != nil {
-			char.Equipment.MainHand = mainHand
-		}
-		if offHand != nil {
-			char.Equipment.OffHand = offHand
+		if len(opts.PinSpells) > 0 || len(opts.ExcludeSpells) > 0 {
+			return err
 		}
-		if armor != nil {
-			char.Equipment.Armor = armor
+		log.Println("failed to get spells:", err)
+	}
+	for _, spell := range added {
+		switch {
+		case spell.Level == 0:
+			fmt.Printf("Added cantrip %s\n", spell.Name)
+		case spell.Prepared:
+			fmt.Printf("Prepared spell %s\n", spell.Name)
+		case hasSpellbook(char):
+			fmt.Printf("Wrote %s into the spellbook\n", spell.Name)
+		default:
+			fmt.Printf("Added spell %s\n", spell.Name)
 		}
-		if shield != nil {
-			char.Equipment.Shield = shield
+	}
+	if err == nil && len(added) == 0 {
+		fmt.Println("No spells missing")
+	}
+
+	if option > 0 {
+		var equipped []string
+		char.Equipment, equipped = fillEmptySlots(char.Equipment, options[option-1].Equipment)
+		char.CalculateCombatStats()
+		if len(equipped) > 0 {
+			fmt.Printf("Equipped %s\n", strings.Join(equipped, ", "))
+		} else {
+			fmt.Println("No empty equipment slots to fill")
 		}
 	}
 
-	if err := storage.SaveCharacter(char); err != nil {
+	if err := saveCharacterChange("enrich", before, char); err != nil {
 		return fmt.Errorf("failed to save enriched character: %w", err)
 	}
 
 	fmt.Println("Character enriched successfully!")
 	return nil
 }
+
+// pickSpells adds random class spells until the character has as many
+// cantrips and spells as SpellLimitsFor allows, choosing from the levels it
+// has slots for. Prepared casters get the spells prepared, starting from the
+// spells they already have; wizards first fill their spellbook and prepare
+// from it. Pinned spells are added first; excluded spells are never picked.
+func pickSpells(ctx context.Context, char *models.Character, opts EnrichOptions) ([]models.Spell, error) {
+	limits := SpellLimitsFor(*char)
+	maxLevel := maxSpellLevel(*char)
+	if limits.Cantrips == 0 && (limits.Spells == 0 || maxLevel == 0) {
+		if len(opts.PinSpells) > 0 {
+			return nil, fmt.Errorf("%s can't have any spells at level %d", char.Name, char.Level)
+		}
+		return nil, nil
+	}
+
+	levels := map[int]bool{0: limits.Cantrips > 0}
+	for lvl := 1; lvl <= maxLevel && limits.Spells > 0; lvl++ {
+		levels[lvl] = true
+	}
+	apiSpells, err := api.ClassSpells(ctx, char.Class, levels)
+	if err != nil {
+		return nil, err
+	}
+	classSpells := map[string]models.Spell{}
+	for _, spell := range apiSpells {
+		classSpells[strings.ToLower(spell.Name)] = spell.Spell()
+	}
+
+	spells := append([]models.Spell(nil), char.Spells...)
+	known := map[string]int{}
+	cantrips, leveled, written := 0, 0, 0
+	for i, spell := range spells {
+		known[strings.ToLower(spell.Name)] = i
+		if spell.Level == 0 {
+			cantrips++
+		} else if spell.Prepared || !limits.Prepared {
+			leveled++
+		}
+		if spell.Level > 0 && !spell.Copied {
+			written++
+		}
+	}
+
+	// add gives the character the spell, or prepares it when the character
+	// already has it unprepared.
+	var added []models.Spell
+	add := func(spell models.Spell) {
+		key := strings.ToLower(spell.Name)
+		if i, ok := known[key]; ok {
+			spells[i].Prepared = true
+			added = append(added, spells[i])
+			leveled++
+			return
+		}
+		spell.Prepared = limits.Prepared && spell.Level > 0
+		spells = append(spells, spell)
+		known[key] = len(spells) - 1
+		added = append(added, spell)
+		if spell.Level == 0 {
+			cantrips++
+		} else {
+			leveled++
+			written++
+		}
+	}
+	// write puts the spell into a wizard's spellbook without preparing it.
+	write := func(spell models.Spell) {
+		spells = append(spells, spell)
+		known[strings.ToLower(spell.Name)] = len(spells) - 1
+		added = append(added, spell)
+		written++
+	}
+
+	excluded := map[string]bool{}
+	for _, name := range opts.ExcludeSpells {
+		excluded[strings.ToLower(name)] = true
+	}
+	for _, name := range opts.PinSpells {
+		key := strings.ToLower(name)
+		if excluded[key] {
+			return nil, fmt.Errorf("spell %s is both pinned and excluded", name)
+		}
+		spell, ok := classSpells[key]
+		if !ok {
+			return nil, fmt.Errorf("pinned spell %s is not a %s spell %s can cast", name, char.Class, char.Name)
+		}
+		if i, ok := known[key]; !ok || (limits.Prepared && spell.Level > 0 && !spells[i].Prepared) {
+			add(spell)
+		}
+		if cantrips > limits.Cantrips || leveled > limits.Spells {
+			return nil, fmt.Errorf("pinning %s goes over the limit of %d cantrips and %d %s", name, limits.Cantrips, limits.Spells, limitNoun(limits))
+		}
+		if limits.Spellbook > 0 && written > limits.Spellbook {
+			return nil, fmt.Errorf("pinning %s goes over the %d spells of %s's spellbook", name, limits.Spellbook, char.Name)
+		}
+	}
+
+	// Wizards prepare from their spellbook, so spells they don't have yet are
+	// written into the book first and prepared from there.
+	var cantripPool, spellPool, bookPool []models.Spell
+	for key, spell := range classSpells {
+		if excluded[key] {
+			continue
+		}
+		i, ok := known[key]
+		switch {
+		case ok && !(limits.Prepared && spell.Level > 0 && !spells[i].Prepared):
+		case spell.Level == 0:
+			cantripPool = append(cantripPool, spell)
+		case !ok && limits.Spellbook > 0:
+			bookPool = append(bookPool, spell)
+		default:
+			spellPool = append(spellPool, spell)
+		}
+	}
+	rng := opts.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	for _, spell := range shuffledSpells(bookPool, limits.Spellbook-written, rng) {
+		write(spell)
+		spellPool = append(spellPool, spell)
+	}
+	for _, spell := range shuffledSpells(cantripPool, limits.Cantrips-cantrips, rng) {
+		add(spell)
+	}
+	for _, spell := range shuffledSpells(spellPool, limits.Spells-leveled, rng) {
+		add(spell)
+	}
+
+	char.Spells = spells
+	return added, nil
+}
+
+// shuffledSpells picks up to need spells from pool at random. The pool is
+// sorted first so a seed always gives the same spells.
+func shuffledSpells(pool []models.Spell, need int, rng *rand.Rand) []models.Spell {
+	sort.Slice(pool, func(i, j int) bool {
+		if pool[i].Level != pool[j].Level {
+			return pool[i].Level < pool[j].Level
+		}
+		return pool[i].Name < pool[j].Name
+	})
+	rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	return pool[:min(max(need, 0), len(pool))]
+}
+
+func limitNoun(limits SpellLimits) string {
+	if limits.Prepared {
+		return "prepared spells"
+	}
+	return "spells known"
+}
@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"dnd-character-sheet/api"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+// bardSpells is the bard spell list served by newSRDServer, by level.
+var bardSpells = map[int][]string{
+	0: {"Dancing Lights", "Light", "Mage Hand", "Mending", "Message", "Minor Illusion", "Vicious Mockery"},
+	1: {"Charm Person", "Cure Wounds", "Detect Magic", "Disguise Self", "Faerie Fire", "Healing Word", "Heroism", "Sleep", "Thunderwave"},
+	2: {"Calm Emotions", "Heat Metal", "Hold Person", "Invisibility", "Shatter", "Suggestion"},
+	3: {"Fear", "Hypnotic Pattern"},
+}
+
+// newSRDServer serves the bard spell list and its spells the way dnd5eapi
+// does, and makes it the SRD API for the rest of the test.
+func newSRDServer(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var results []string
+		for level, names := range bardSpells {
+			for _, name := range names {
+				index := strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+				if r.URL.Path == "/api/spells/"+index {
+					fmt.Fprintf(w, `{"name": %q, "level": %d, "classes": [{"name": "Bard"}]}`, name, level)
+					return
+				}
+				results = append(results, fmt.Sprintf(`{"name": %q, "url": "/api/spells/%s", "level": %d}`, name, index, level))
+			}
+		}
+		if r.URL.Path != "/api/classes/bard/spells" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"results": [%s]}`, strings.Join(results, ","))
+	}))
+	t.Cleanup(srv.Close)
+	api.DefaultClient = api.NewClient(srv.URL)
+	api.DefaultClient.RequestInterval = 0
+}
+
+// enrichBard enriches a new level 3 bard with opts and returns its spells.
+func enrichBard(t *testing.T, opts EnrichOptions) []models.Spell {
+	t.Helper()
+	bard := newCaster("bard", 3, 14)
+	if err := storage.SaveAllCharacters(map[string]models.Character{bard.Name: bard}); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnrichCharacter(context.Background(), bard.Name, opts); err != nil {
+		t.Fatal(err)
+	}
+	enriched, err := storage.GetCharacterByName(bard.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enriched.Spells
+}
+
+func spellNames(spells []models.Spell) string {
+	names := make([]string, len(spells))
+	for i, spell := range spells {
+		names[i] = spell.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestEnrichIsRepeatableWithASeed(t *testing.T) {
+	useTempStorage(t)
+	newSRDServer(t)
+
+	for _, seed := range []int64{0, 1, 42} {
+		first := enrichBard(t, EnrichOptions{Rand: rand.New(rand.NewSource(seed))})
+		second := enrichBard(t, EnrichOptions{Rand: rand.New(rand.NewSource(seed))})
+		if spellNames(first) != spellNames(second) {
+			t.Errorf("seed %d picked %s, then %s", seed, spellNames(first), spellNames(second))
+		}
+	}
+
+	picks := map[string]bool{}
+	for seed := range int64(5) {
+		picks[spellNames(enrichBard(t, EnrichOptions{Rand: rand.New(rand.NewSource(seed))}))] = true
+	}
+	if len(picks) == 1 {
+		t.Error("five seeds picked the same spells")
+	}
+}
+
+func TestEnrichFillsLimits(t *testing.T) {
+	useTempStorage(t)
+	newSRDServer(t)
+
+	spells := enrichBard(t, EnrichOptions{
+		Rand:          rand.New(rand.NewSource(7)),
+		PinSpells:     []string{"Heat Metal", "vicious mockery"},
+		ExcludeSpells: []string{"Sleep"},
+	})
+	counts := countByLevel(models.Character{Spells: spells})
+	if counts[0] != 2 || counts[1]+counts[2] != 6 || counts[3] != 0 {
+		t.Errorf("a level 3 bard got %v spells by level, want 2 cantrips and 6 spells of level 1 or 2", counts)
+	}
+	names := spellNames(spells)
+	for _, pinned := range []string{"Heat Metal", "Vicious Mockery"} {
+		if !strings.Contains(names, pinned) {
+			t.Errorf("pinned %s is missing from %s", pinned, names)
+		}
+	}
+	if strings.Contains(names, "Sleep") {
+		t.Errorf("excluded Sleep was picked: %s", names)
+	}
+}
+
+func TestEnrichKeepsEquipment(t *testing.T) {
+	useTempStorage(t)
+	newSRDServer(t)
+	bard := newCaster("bard", 3, 14)
+	longsword := Weapons["longsword"]
+	bard.Equipment.MainHand = &longsword
+	saveTestCharacter(t, bard)
+
+	if err := EnrichCharacter(context.Background(), bard.Name, EnrichOptions{EquipmentOption: 1}); err != nil {
+		t.Fatal(err)
+	}
+	enriched, err := storage.GetCharacterByName(bard.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equipment := enriched.Equipment
+	if equipment.MainHand == nil || equipment.MainHand.Name != "longsword" {
+		t.Errorf("main hand %+v, want the longsword kept", equipment.MainHand)
+	}
+	if equipment.OffHand == nil || equipment.OffHand.Name != "dagger" || equipment.Armor == nil {
+		t.Errorf("off hand %+v, armor %+v; want the dagger and leather armor added", equipment.OffHand, equipment.Armor)
+	}
+}
+
+func TestFillEmptySlots(t *testing.T) {
+	greatsword, dagger := Weapons["greatsword"], Weapons["dagger"]
+	shield := Shields["shield"]
+	loadout := models.Equipment{MainHand: &dagger, OffHand: &dagger, Shield: &shield}
+
+	filled, added := fillEmptySlots(models.Equipment{MainHand: &greatsword}, loadout)
+	if filled.MainHand.Name != "greatsword" || filled.OffHand != nil || filled.Shield != nil || len(added) != 0 {
+		t.Errorf("next to a greatsword: %+v, added %v", filled, added)
+	}
+	filled, added = fillEmptySlots(models.Equipment{}, loadout)
+	if filled.MainHand == nil || filled.OffHand == nil || filled.Shield != nil || len(added) != 2 {
+		t.Errorf("empty hands: %+v, added %v; want two daggers and no shield", filled, added)
+	}
+}
+
+func TestEnrichWithoutStartingEquipment(t *testing.T) {
+	useTempStorage(t)
+	newSRDServer(t)
+	loadouts := ClassStartingEquipment["bard"]
+	delete(ClassStartingEquipment, "bard")
+	t.Cleanup(func() { ClassStartingEquipment["bard"] = loadouts })
+
+	if spells := enrichBard(t, EnrichOptions{}); len(spells) == 0 {
+		t.Error("a class without starting equipment got no spells")
+	}
+	err := EnrichCharacter(context.Background(), "Test bard", EnrichOptions{EquipmentOption: 1})
+	if !errors.Is(err, ErrNoStartingEquipment) {
+		t.Errorf("choosing equipment without any: %v", err)
+	}
+}
+
+func TestEnrichRejectsPinsOverTheLimit(t *testing.T) {
+	useTempStorage(t)
+	newSRDServer(t)
+	bard := newCaster("bard", 3, 14)
+	saveTestCharacter(t, bard)
+
+	err := EnrichCharacter(context.Background(), bard.Name, EnrichOptions{PinSpells: bardSpells[0][:3]})
+	if err == nil || !strings.Contains(err.Error(), "over the limit") {
+		t.Errorf("pinning 3 cantrips: %v", err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"dnd-character-sheet/storage"
 	"encoding/csv"
 	"fmt"
-	"os"
+	"io/fs"
 	"strings"
 )
 
@@ -39,6 +39,53 @@ var DefaultArmorStats = map[string]models.Armor{
 	"shield": {ArmorClass: 2, DexBonus: false},
 }
 
+var DefaultWeaponStats = map[string]models.Weapon{
+	// Simple melee
+	"club":         {Category: "simple melee"},
+	"dagger":       {Category: "simple melee", Range: "20/60"},
+	"greatclub":    {Category: "simple melee", TwoHanded: true},
+	"handaxe":      {Category: "simple melee", Range: "20/60"},
+	"javelin":      {Category: "simple melee", Range: "30/120"},
+	"light hammer": {Category: "simple melee", Range: "20/60"},
+	"mace":         {Category: "simple melee"},
+	"quarterstaff": {Category: "simple melee"},
+	"sickle":       {Category: "simple melee"},
+	"spear":        {Category: "simple melee", Range: "20/60"},
+
+	// Simple ranged
+	"crossbow, light": {Category: "simple ranged", Range: "80/320", TwoHanded: true},
+	"dart":            {Category: "simple ranged", Range: "20/60"},
+	"shortbow":        {Category: "simple ranged", Range: "80/320", TwoHanded: true},
+	"sling":           {Category: "simple ranged", Range: "30/120"},
+
+	// Martial melee
+	"battleaxe":   {Category: "martial melee"},
+	"flail":       {Category: "martial melee"},
+	"glaive":      {Category: "martial melee", TwoHanded: true},
+	"greataxe":    {Category: "martial melee", TwoHanded: true},
+	"greatsword":  {Category: "martial melee", TwoHanded: true},
+	"halberd":     {Category: "martial melee", TwoHanded: true},
+	"lance":       {Category: "martial melee"},
+	"longsword":   {Category: "martial melee"},
+	"maul":        {Category: "martial melee", TwoHanded: true},
+	"morningstar": {Category: "martial melee"},
+	"pike":        {Category: "martial melee", TwoHanded: true},
+	"rapier":      {Category: "martial melee"},
+	"scimitar":    {Category: "martial melee"},
+	"shortsword":  {Category: "martial melee"},
+	"trident":     {Category: "martial melee", Range: "20/60"},
+	"war pick":    {Category: "martial melee"},
+	"warhammer":   {Category: "martial melee"},
+	"whip":        {Category: "martial melee"},
+
+	// Martial ranged
+	"blowgun":         {Category: "martial ranged", Range: "25/100"},
+	"crossbow, hand":  {Category: "martial ranged", Range: "30/120"},
+	"crossbow, heavy": {Category: "martial ranged", Range: "100/400", TwoHanded: true},
+	"longbow":         {Category: "martial ranged", Range: "150/600", TwoHanded: true},
+	"net":             {Category: "martial ranged", Range: "5/15"},
+}
+
 // ------------------------
 // Helpers
 // ------------------------
@@ -49,11 +96,21 @@ func normalizeName(name string) string {
 	return name
 }
 
+// armorDisplayName puts "armor" back on the armors whose name means nothing
+// without it.
+func armorDisplayName(key string) string {
+	if key == "padded" || key == "leather" || key == "studded leather" || key == "plate" {
+		return key + " armor"
+	}
+	return key
+}
+
 // ------------------------
 // CSV Loader
 // ------------------------
-func LoadEquipmentCSV(filePath string) error {
-	file, err := os.Open(filePath)
+// LoadEquipmentCSV reads the equipment catalog at filePath in fsys.
+func LoadEquipmentCSV(fsys fs.FS, filePath string) error {
+	file, err := fsys.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("could not open equipment CSV: %w", err)
 	}
@@ -99,7 +156,8 @@ func LoadEquipmentCSV(filePath string) error {
 				Armors[strings.ToLower(originalName)] = armor
 			}
 		case "weapon":
-			weapon := models.Weapon{Name: strings.ToLower(originalName)}
+			weapon := DefaultWeaponStats[key]
+			weapon.Name = strings.ToLower(originalName)
 			Weapons[key] = weapon
 			Weapons[strings.ToLower(originalName)] = weapon
 		}
@@ -123,8 +181,9 @@ func AddWeaponToSlot(characterName string, newWeapon models.Weapon, slot string)
 
 	character, exists := characters[characterName]
 	if !exists {
-		return "", fmt.Errorf("character '%s' not found", characterName)
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
 	newWeapon.Name = strings.ToLower(strings.TrimSpace(newWeapon.Name)) // lowercase
 	var hand string
@@ -138,25 +197,25 @@ func AddWeaponToSlot(characterName string, newWeapon models.Weapon, slot string)
 			character.Equipment.OffHand = &newWeapon
 			hand = "off hand"
 		} else {
-			return "", fmt.Errorf("both hands already occupied")
+			return "", invalidf("both hands already occupied")
 		}
 	case "main hand":
 		if character.Equipment.MainHand != nil {
-			return "", fmt.Errorf("main hand already occupied")
+			return "", invalidf("main hand already occupied")
 		}
 		character.Equipment.MainHand = &newWeapon
 		hand = "main hand"
 	case "off hand":
 		if character.Equipment.OffHand != nil {
-			return "", fmt.Errorf("off hand already occupied")
+			return "", invalidf("off hand already occupied")
 		}
 		character.Equipment.OffHand = &newWeapon
 		hand = "off hand"
 	default:
-		return "", fmt.Errorf("invalid slot: must be 'main hand' or 'off hand'")
+		return "", invalidf("invalid slot: must be 'main hand' or 'off hand'")
 	}
 
-	if err := storage.SaveCharacter(character); err != nil {
+	if err := saveCharacterChange("equip", before, character); err != nil {
 		return "", fmt.Errorf("could not save character: %w", err)
 	}
 
@@ -171,8 +230,9 @@ func RemoveWeapon(characterName, weaponName string) error {
 
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character '%s' not found", characterName)
+		return loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
 	weaponName = normalizeName(weaponName)
 	removed := false
@@ -186,10 +246,10 @@ func RemoveWeapon(characterName, weaponName string) error {
 	}
 
 	if !removed {
-		return fmt.Errorf("weapon '%s' not found on character '%s'", weaponName, characterName)
+		return invalidf("weapon '%s' not found on character '%s'", weaponName, characterName)
 	}
 
-	if err := storage.SaveCharacter(character); err != nil {
+	if err := saveCharacterChange("unequip", before, character); err != nil {
 		return fmt.Errorf("could not save character: %w", err)
 	}
 
@@ -199,39 +259,36 @@ func RemoveWeapon(characterName, weaponName string) error {
 // ------------------------
 // Armor & Shield functions
 // ------------------------
-func AddArmor(characterName, armorName string) error {
+func AddArmor(characterName, armorName string) (string, error) {
 	characters, err := storage.LoadCharacters()
 	if err != nil {
-		return fmt.Errorf("could not load characters: %w", err)
+		return "", fmt.Errorf("could not load characters: %w", err)
 	}
 
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character '%s' not found", characterName)
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
 	key := strings.ToLower(strings.TrimSpace(armorName))
 	armor, ok := Armors[key]
 	if !ok {
-		return fmt.Errorf("armor '%s' not found", armorName)
+		return "", invalidf("armor '%s' not found", armorName)
 	}
 
-	displayName := key
-	if key == "padded" || key == "leather" || key == "studded leather" || key == "plate" {
-		displayName += " armor"
-	}
+	displayName := armorDisplayName(key)
 
 	displayArmor := armor
 	displayArmor.Name = displayName
 	character.Equipment.Armor = &displayArmor
 	character.CalculateCombatStats()
 
-	if err := storage.SaveCharacter(character); err != nil {
-		return fmt.Errorf("could not save character: %w", err)
+	if err := saveCharacterChange("equip", before, character); err != nil {
+		return "", fmt.Errorf("could not save character: %w", err)
 	}
 
-	fmt.Printf("Equipped armor %s\n", displayName)
-	return nil
+	return displayName, nil
 }
 
 func RemoveArmor(characterName string) error {
@@ -242,13 +299,14 @@ func RemoveArmor(characterName string) error {
 
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character '%s' not found", characterName)
+		return loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
 	character.Equipment.Armor = nil
 	character.CalculateCombatStats()
 
-	if err := storage.SaveCharacter(character); err != nil {
+	if err := saveCharacterChange("unequip", before, character); err != nil {
 		return fmt.Errorf("could not save character: %w", err)
 	}
 
@@ -263,15 +321,16 @@ func AddShield(characterName, shieldName string) error {
 
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character '%s' not found", characterName)
+		return loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
 	key := normalizeName(shieldName)
 	shield, ok := Shields[key]
 	if !ok {
 		shield, ok = Shields[strings.ToLower(shieldName)]
 		if !ok {
-			return fmt.Errorf("shield '%s' not found", shieldName)
+			return invalidf("shield '%s' not found", shieldName)
 		}
 	}
 
@@ -280,7 +339,7 @@ func AddShield(characterName, shieldName string) error {
 	character.Equipment.Shield = &displayShield
 	character.CalculateCombatStats()
 
-	if err := storage.SaveCharacter(character); err != nil {
+	if err := saveCharacterChange("equip", before, character); err != nil {
 		return fmt.Errorf("could not save character: %w", err)
 	}
 
@@ -295,13 +354,14 @@ func RemoveShield(characterName string) error {
 
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character '%s' not found", characterName)
+		return loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
 	character.Equipment.Shield = nil
 	character.CalculateCombatStats()
 
-	if err := storage.SaveCharacter(character); err != nil {
+	if err := saveCharacterChange("unequip", before, character); err != nil {
 		return fmt.Errorf("could not save character: %w", err)
 	}
 
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid matches errors caused by what a command was asked to do, such
+// as a rule the change would break or an unknown spell, as opposed to
+// failing to load or save.
+var ErrInvalid = errors.New("invalid request")
+
+type invalidError struct {
+	err error
+}
+
+func (e *invalidError) Error() string        { return e.err.Error() }
+func (e *invalidError) Unwrap() error        { return e.err }
+func (e *invalidError) Is(target error) bool { return target == ErrInvalid }
+
+// invalidf formats an error like fmt.Errorf that matches ErrInvalid.
+func invalidf(format string, args ...interface{}) error {
+	return &invalidError{fmt.Errorf(format, args...)}
+}
+
+// invalid marks err, such as a failed models validation, as matching
+// ErrInvalid.
+func invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &invalidError{err}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+	"fmt"
+)
+
+// saveCharacterChange saves the character and appends the changes made by
+// command to the character's history.
+func saveCharacterChange(command string, before, after models.Character) error {
+	if err := storage.SaveCharacter(after); err != nil {
+		return err
+	}
+	if err := storage.RecordChange(command, before, after); err != nil {
+		return fmt.Errorf("saved character but failed to record history: %w", err)
+	}
+	return nil
+}
+
+func ShowHistory(characterName string) error {
+	if _, err := storage.GetCharacterByName(characterName); err != nil {
+		return loadError(characterName, err)
+	}
+
+	history, err := storage.LoadHistory(characterName)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("No history for %s\n", characterName)
+		return nil
+	}
+
+	for _, entry := range history {
+		fmt.Printf("#%d %s %s", entry.Sequence, entry.Timestamp.Local().Format("2006-01-02 15:04:05"), entry.Command)
+		if len(entry.Reverts) > 0 {
+			fmt.Printf(" (reverts %v)", entry.Reverts)
+		}
+		fmt.Println()
+		for _, change := range entry.Changes {
+			fmt.Printf("    %s\n", change)
+		}
+	}
+	return nil
+}
+
+func UndoCharacter(characterName string, steps int) error {
+	_, undone, err := storage.UndoChanges(characterName, steps)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range undone {
+		fmt.Printf("Undid #%d %s\n", entry.Sequence, entry.Command)
+	}
+	return nil
+}
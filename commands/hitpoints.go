@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"dnd-character-sheet/storage"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func DamageCharacter(characterName string, amount int) (string, error) {
+	if amount < 0 {
+		return "", invalidf("damage must not be negative")
+	}
+
+	character, err := storage.GetCharacterByName(characterName)
+	if err != nil {
+		return "", loadError(characterName, err)
+	}
+	before := character.Clone()
+
+	absorbed := min(amount, character.TemporaryHitPoints)
+	character.TemporaryHitPoints -= absorbed
+	character.CurrentHitPoints = max(character.CurrentHitPoints-(amount-absorbed), 0)
+
+	if err := saveCharacterChange("damage", before, character); err != nil {
+		return "", fmt.Errorf("cannot save character: %w", err)
+	}
+
+	summary := fmt.Sprintf("%s takes %d damage (%d/%d HP", character.Name, amount, character.CurrentHitPoints, character.MaxHitPoints)
+	if character.TemporaryHitPoints > 0 {
+		summary += fmt.Sprintf(", %d temporary", character.TemporaryHitPoints)
+	}
+	return summary + ")", nil
+}
+
+func HealCharacter(characterName string, amount int) (string, error) {
+	if amount < 0 {
+		return "", invalidf("healing must not be negative")
+	}
+
+	character, err := storage.GetCharacterByName(characterName)
+	if err != nil {
+		return "", loadError(characterName, err)
+	}
+	before := character.Clone()
+
+	if character.CurrentHitPoints == 0 && amount > 0 {
+		character.DeathSaveSuccesses = 0
+		character.DeathSaveFailures = 0
+	}
+	character.CurrentHitPoints = min(character.CurrentHitPoints+amount, character.MaxHitPoints)
+
+	if err := saveCharacterChange("heal", before, character); err != nil {
+		return "", fmt.Errorf("cannot save character: %w", err)
+	}
+
+	return fmt.Sprintf("%s heals %d (%d/%d HP)", character.Name, amount, character.CurrentHitPoints, character.MaxHitPoints), nil
+}
+
+// RestCharacter applies a short or long rest. A short rest spends up to
+// hitDice hit dice, each healing the die's average plus the Constitution
+// modifier, and restores a warlock's pact magic slots. A long rest restores
+// all hit points, spell slots and half the character's hit dice. It returns a
+// summary of the character after the rest.
+func RestCharacter(characterName, kind string, hitDice int) (string, error) {
+	character, err := storage.GetCharacterByName(characterName)
+	if err != nil {
+		return "", loadError(characterName, err)
+	}
+	before := character.Clone()
+
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	remaining := character.RemainingHitDice()
+	switch kind {
+	case "short":
+		if hitDice < 0 {
+			return "", invalidf("hit dice must not be negative")
+		}
+		if hitDice > remaining {
+			return "", invalidf("only %d hit dice remaining", remaining)
+		}
+		perDie := max(character.HitDie()/2+1+character.Abilities.Modifier("Constitution"), 0)
+		character.CurrentHitPoints = min(character.CurrentHitPoints+hitDice*perDie, character.MaxHitPoints)
+		character.SetRemainingHitDice(remaining - hitDice)
+		if strings.EqualFold(character.Class, "warlock") {
+			character.SpellSlotsUsed = nil
+		}
+	case "long":
+		character.CurrentHitPoints = character.MaxHitPoints
+		character.TemporaryHitPoints = 0
+		character.DeathSaveSuccesses = 0
+		character.DeathSaveFailures = 0
+		character.SpellSlotsUsed = nil
+		character.SetRemainingHitDice(min(remaining+max(character.Level/2, 1), character.Level))
+	default:
+		return "", invalidf("rest must be 'short' or 'long', got '%s'", kind)
+	}
+
+	if err := saveCharacterChange(kind+"-rest", before, character); err != nil {
+		return "", fmt.Errorf("cannot save character: %w", err)
+	}
+
+	return fmt.Sprintf("%s finishes a %s rest (%d/%d HP, %s hit dice)", character.Name, kind, character.CurrentHitPoints, character.MaxHitPoints, character.HitDiceRemaining), nil
+}
+
+// loadError describes a character that couldn't be loaded. Only a missing
+// character is reported as not found; the storage error is kept either way.
+func loadError(characterName string, err error) error {
+	if errors.Is(err, storage.ErrCharacterNotFound) {
+		return fmt.Errorf("%w: %s", err, characterName)
+	}
+	return fmt.Errorf("cannot load character '%s': %w", characterName, err)
+}
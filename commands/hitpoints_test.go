@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"dnd-character-sheet/storage"
+)
+
+func TestDamageAndHeal(t *testing.T) {
+	useTempStorage(t)
+	fighter := newCaster("fighter", 1, 10)
+	fighter.MaxHitPoints = 10
+	fighter.CurrentHitPoints = 10
+	fighter.TemporaryHitPoints = 3
+	saveTestCharacter(t, fighter)
+
+	summary, err := DamageCharacter(fighter.Name, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Test fighter takes 5 damage (8/10 HP)"; summary != want {
+		t.Errorf("damage summary %q, want %q", summary, want)
+	}
+	summary, err = HealCharacter(fighter.Name, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Test fighter heals 4 (10/10 HP)"; summary != want {
+		t.Errorf("heal summary %q, want %q", summary, want)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	useTempStorage(t)
+	saveTestCharacter(t, newCaster("fighter", 1, 10))
+
+	if _, err := HealCharacter("Test fighter", -1); !errors.Is(err, ErrInvalid) {
+		t.Errorf("negative healing: %v, want ErrInvalid", err)
+	}
+	if _, err := RestCharacter("Test fighter", "nap", 0); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown rest: %v, want ErrInvalid", err)
+	}
+	if _, err := DamageCharacter("Nobody", 1); !errors.Is(err, storage.ErrCharacterNotFound) || errors.Is(err, ErrInvalid) {
+		t.Errorf("damaging a missing character: %v, want only ErrCharacterNotFound", err)
+	}
+	if err := ExportCharacter("Nobody", filepath.Join(t.TempDir(), "nobody.json")); !errors.Is(err, storage.ErrCharacterNotFound) {
+		t.Errorf("exporting a missing character: %v, want ErrCharacterNotFound", err)
+	}
+}
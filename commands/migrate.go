@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"dnd-character-sheet/storage"
+	"fmt"
+)
+
+func MigrateCharacters(dryRun bool) error {
+	var results []storage.MigrationResult
+	var err error
+	if dryRun {
+		results, err = storage.PlanMigrations()
+	} else {
+		results, err = storage.MigrateAll()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate characters: %w", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("All characters are at schema version %d\n", storage.CurrentSchemaVersion())
+		return nil
+	}
+
+	for _, result := range results {
+		fmt.Printf("%s: schema version %d -> %d\n", result.Name, result.FromVersion, result.ToVersion)
+		for _, applied := range result.Applied {
+			fmt.Printf("  * %s\n", applied)
+		}
+		for _, change := range result.Changes {
+			fmt.Printf("    %s\n", change)
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run: %d character(s) would be migrated\n", len(results))
+	} else {
+		fmt.Printf("Migrated %d character(s)\n", len(results))
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package commands
+
+import (
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// ------------------------
+// Layout
+// ------------------------
+const (
+	pdfMargin      = 12.0
+	pdfPageWidth   = 210.0
+	pdfContentWide = pdfPageWidth - 2*pdfMargin
+	pdfLineHeight  = 5.0
+)
+
+var abilityOrder = []string{"Strength", "Dexterity", "Constitution", "Intelligence", "Wisdom", "Charisma"}
+
+type sheetPDF struct {
+	pdf *fpdf.Fpdf
+	tr  func(string) string
+}
+
+func ExportCharacterPDF(characterName, filePath string) error {
+	character, err := storage.GetCharacterByName(characterName)
+	if err != nil {
+		return loadError(characterName, err)
+	}
+
+	character.CalculateCombatStats()
+
+	pdf := renderCharacterPDF(character)
+	if err := pdf.OutputFileAndClose(filePath); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	fmt.Printf("Exported %s to %s\n", character.Name, filePath)
+	return nil
+}
+
+func renderCharacterPDF(c models.Character) *fpdf.Fpdf {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetTitle(c.Name+" - Character Sheet", true)
+
+	sheet := &sheetPDF{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+	sheet.statsPage(c)
+	sheet.combatPage(c)
+	sheet.spellsPage(c)
+	return pdf
+}
+
+// ------------------------
+// Pages
+// ------------------------
+func (s *sheetPDF) statsPage(c models.Character) {
+	s.pdf.AddPage()
+	s.header(c)
+
+	top := s.pdf.GetY() + 4
+	left := pdfMargin
+	s.pdf.SetXY(left, top)
+	for _, ability := range abilityOrder {
+		x, y := s.pdf.GetXY()
+		s.pdf.RoundedRect(x, y, 28, 30, 2, "1234", "D")
+		s.text(x, y+2, 28, 4, strings.ToUpper(ability), "B", 7, "C")
+		s.text(x, y+8, 28, 10, strconv.Itoa(c.Abilities.Score(ability)), "B", 18, "C")
+		s.text(x, y+21, 28, 6, models.FormatModifier(c.Abilities.Modifier(ability)), "", 11, "C")
+		s.pdf.SetXY(x, y+33)
+	}
+
+	middle := left + 34
+	s.pdf.SetXY(middle, top)
+	s.labelledBox("Proficiency Bonus", models.FormatModifier(c.ProficiencyBonus), 70)
+	s.labelledBox("Passive Wisdom (Perception)", strconv.Itoa(c.PassivePerception), 70)
+	s.pdf.SetX(middle)
+	s.row([]float64{35, 35}, []string{
+		fmt.Sprintf("Passive Investigation %d", c.PassiveInvestigation),
+		fmt.Sprintf("Passive Insight %d", c.PassiveInsight),
+	})
+	s.pdf.Ln(2)
+
+	s.pdf.SetX(middle)
+	s.sectionTitle("Saving Throws", 70)
+	for _, ability := range abilityOrder {
+		marker := "o"
+		if c.HasSavingThrowProficiency(ability) {
+			marker = "*"
+		}
+		s.pdf.SetX(middle)
+		s.row([]float64{6, 14, 50}, []string{marker, models.FormatModifier(c.SavingThrows[ability]), ability})
+	}
+	s.pdf.Ln(2)
+
+	s.pdf.SetX(middle)
+	s.sectionTitle("Skills", 70)
+	skills := make([]string, 0, len(models.SkillAbilities))
+	for skill := range models.SkillAbilities {
+		skills = append(skills, skill)
+	}
+	sort.Strings(skills)
+	for _, skill := range skills {
+		marker := "o"
+		switch c.SkillProficiencyLevel(skill) {
+		case models.HalfProficient:
+			marker = "½"
+		case models.Proficient:
+			marker = "*"
+		case models.Expertise:
+			marker = "**"
+		}
+		ability := models.SkillAbilities[skill]
+		s.pdf.SetX(middle)
+		s.row([]float64{6, 14, 50}, []string{marker, models.FormatModifier(c.Skills[skill]), fmt.Sprintf("%s (%s)", skill, ability[:3])})
+	}
+
+	right := middle + 76
+	s.pdf.SetXY(right, top)
+	for _, block := range []struct{ label, value string }{
+		{"Personality Traits", c.Personality},
+		{"Ideals", c.Ideals},
+		{"Bonds", c.Bonds},
+		{"Flaws", c.Flaws},
+		{"Features & Traits", c.Features},
+	} {
+		s.pdf.SetX(right)
+		s.textBlock(block.label, block.value, pdfMargin+pdfContentWide-right)
+	}
+}
+
+func (s *sheetPDF) combatPage(c models.Character) {
+	s.pdf.AddPage()
+	s.header(c)
+	s.pdf.Ln(4)
+
+	width := pdfContentWide / 3
+	y := s.pdf.GetY()
+	for i, stat := range []struct{ label, value string }{
+		{"Armor Class", strconv.Itoa(c.ArmorClass)},
+		{"Initiative", models.FormatModifier(c.Initiative)},
+		{"Speed", fmt.Sprintf("%d ft", c.Speed)},
+	} {
+		s.pdf.SetXY(pdfMargin+float64(i)*width, y)
+		s.labelledBox(stat.label, stat.value, width-4)
+	}
+
+	y = s.pdf.GetY()
+	for i, stat := range []struct{ label, value string }{
+		{"Hit Point Maximum", strconv.Itoa(c.MaxHitPoints)},
+		{"Current Hit Points", strconv.Itoa(c.CurrentHitPoints)},
+		{"Temporary Hit Points", strconv.Itoa(c.TemporaryHitPoints)},
+	} {
+		s.pdf.SetXY(pdfMargin+float64(i)*width, y)
+		s.labelledBox(stat.label, stat.value, width-4)
+	}
+
+	y = s.pdf.GetY()
+	s.pdf.SetXY(pdfMargin, y)
+	s.labelledBox("Hit Dice", fmt.Sprintf("%s / %s", orDash(c.HitDiceRemaining), orDash(c.HitDiceTotal)), width-4)
+	s.pdf.SetXY(pdfMargin+width, y)
+	s.labelledBox("Death Saves", fmt.Sprintf("Successes %s   Failures %s",
+		bubbles(c.DeathSaveSuccesses), bubbles(c.DeathSaveFailures)), 2*width-4)
+
+	s.pdf.SetX(pdfMargin)
+	s.sectionTitle("Attacks", pdfContentWide)
+	s.tableHeader([]float64{40, 60, 40, 46}, []string{"Slot", "Weapon", "Range", "Notes"})
+	for _, attack := range []struct {
+		slot   string
+		weapon *models.Weapon
+	}{
+		{"Main hand", c.Equipment.MainHand},
+		{"Off hand", c.Equipment.OffHand},
+	} {
+		if attack.weapon == nil {
+			continue
+		}
+		notes := attack.weapon.Category
+		if attack.weapon.TwoHanded {
+			notes = strings.TrimSpace(notes + " two-handed")
+		}
+		s.row([]float64{40, 60, 40, 46}, []string{attack.slot, attack.weapon.Name, orDash(attack.weapon.Range), notes})
+	}
+
+	s.pdf.Ln(2)
+	s.sectionTitle("Armor", pdfContentWide)
+	if c.Equipment.Armor != nil {
+		armor := c.Equipment.Armor
+		detail := fmt.Sprintf("AC %d", armor.ArmorClass)
+		if armor.DexBonus {
+			detail += " + DEX"
+			if armor.MaxDexBonus > 0 {
+				detail += fmt.Sprintf(" (max %d)", armor.MaxDexBonus)
+			}
+		}
+		s.row([]float64{60, 126}, []string{armor.Name, detail})
+	}
+	if c.Equipment.Shield != nil {
+		s.row([]float64{60, 126}, []string{c.Equipment.Shield.Name, fmt.Sprintf("+%d AC", c.Equipment.Shield.ArmorClass)})
+	}
+
+	s.pdf.Ln(2)
+	s.sectionTitle("Equipment", pdfContentWide)
+	s.row([]float64{37, 37, 37, 37, 38}, []string{
+		fmt.Sprintf("CP %d", c.CopperPieces),
+		fmt.Sprintf("SP %d", c.SilverPieces),
+		fmt.Sprintf("EP %d", c.ElectrumPieces),
+		fmt.Sprintf("GP %d", c.GoldPieces),
+		fmt.Sprintf("PP %d", c.PlatinumPieces),
+	})
+	if c.EquipmentText != "" {
+		s.pdf.SetFont("Helvetica", "", 9)
+		s.pdf.MultiCell(pdfContentWide, pdfLineHeight, s.tr(c.EquipmentText), "", "L", false)
+	}
+}
+
+func (s *sheetPDF) spellsPage(c models.Character) {
+	s.pdf.AddPage()
+	s.header(c)
+	s.pdf.Ln(4)
+
+	if c.SpellcastingAbility == "" && len(c.Spells) == 0 {
+		s.pdf.SetFont("Helvetica", "I", 10)
+		s.pdf.CellFormat(pdfContentWide, 8, s.tr(fmt.Sprintf("%s has no spellcasting.", c.Name)), "", 1, "L", false, 0, "")
+		return
+	}
+
+	width := pdfContentWide / 4
+	y := s.pdf.GetY()
+	for i, stat := range []struct{ label, value string }{
+		{"Spellcasting Class", titleCase(c.Class)},
+		{"Spellcasting Ability", titleCase(c.SpellcastingAbility)},
+		{"Spell Save DC", strconv.Itoa(c.SpellSaveDC)},
+		{"Spell Attack Bonus", models.FormatModifier(c.SpellAttackBonus)},
+	} {
+		s.pdf.SetXY(pdfMargin+float64(i)*width, y)
+		s.labelledBox(stat.label, stat.value, width-4)
+	}
+
+	levels := map[int]bool{}
+	for lvl := range c.SpellSlots {
+		levels[lvl] = true
+	}
+	for _, spell := range c.Spells {
+		levels[spell.Level] = true
+	}
+	sorted := make([]int, 0, len(levels))
+	for lvl := range levels {
+		sorted = append(sorted, lvl)
+	}
+	sort.Ints(sorted)
+
+	s.pdf.SetX(pdfMargin)
+	for _, lvl := range sorted {
+		title := "Cantrips"
+		if lvl > 0 {
+			title = fmt.Sprintf("Level %d", lvl)
+		}
+		if slots, ok := c.SpellSlots[lvl]; ok && lvl > 0 {
+			title += fmt.Sprintf("  -  %d slot(s)", slots)
+		}
+		s.sectionTitle(title, pdfContentWide)
+		for _, spell := range c.Spells {
+			if spell.Level != lvl {
+				continue
+			}
+			marker := ""
+			if spell.Prepared {
+				marker = "*"
+			}
+			s.row([]float64{6, 80, 50, 50}, []string{marker, spell.Name, spell.School, spell.Range})
+		}
+		s.pdf.Ln(1)
+	}
+}
+
+// ------------------------
+// Drawing helpers
+// ------------------------
+func (s *sheetPDF) header(c models.Character) {
+	s.pdf.SetFont("Helvetica", "B", 18)
+	s.pdf.CellFormat(pdfContentWide, 10, s.tr(c.Name), "B", 1, "L", false, 0, "")
+
+	classLevel := strings.TrimSpace(fmt.Sprintf("%s %d", titleCase(c.Class), c.Level))
+	fields := []struct{ label, value string }{
+		{"Class & Level", classLevel},
+		{"Background", titleCase(c.Background)},
+		{"Player Name", c.PlayerName},
+		{"Race", titleCase(c.Race)},
+		{"Alignment", c.Alignment},
+		{"Experience Points", strconv.Itoa(c.ExperiencePoints)},
+	}
+	width := pdfContentWide / 3
+	top := s.pdf.GetY()
+	for i, field := range fields {
+		x := pdfMargin + float64(i%3)*width
+		y := top + float64(i/3)*9
+		s.text(x, y+1, width, 5, field.value, "", 10, "L")
+		s.text(x, y+5.5, width, 3, strings.ToUpper(field.label), "", 6, "L")
+	}
+	s.pdf.SetXY(pdfMargin, top+18)
+}
+
+func (s *sheetPDF) text(x, y, w, h float64, value, style string, size float64, align string) {
+	s.pdf.SetXY(x, y)
+	s.pdf.SetFont("Helvetica", style, size)
+	s.pdf.CellFormat(w, h, s.tr(value), "", 0, align, false, 0, "")
+}
+
+func (s *sheetPDF) labelledBox(label, value string, width float64) {
+	x, y := s.pdf.GetXY()
+	s.pdf.RoundedRect(x, y, width, 16, 2, "1234", "D")
+	s.text(x, y+2, width, 8, value, "B", 14, "C")
+	s.text(x, y+11, width, 4, strings.ToUpper(label), "", 6, "C")
+	s.pdf.SetXY(x, y+19)
+}
+
+func (s *sheetPDF) sectionTitle(title string, width float64) {
+	s.pdf.SetFont("Helvetica", "B", 10)
+	s.pdf.SetFillColor(225, 225, 225)
+	x := s.pdf.GetX()
+	s.pdf.CellFormat(width, 6, s.tr(title), "", 1, "L", true, 0, "")
+	s.pdf.SetX(x)
+}
+
+func (s *sheetPDF) tableHeader(widths []float64, headers []string) {
+	s.pdf.SetFont("Helvetica", "B", 8)
+	for i, header := range headers {
+		s.pdf.CellFormat(widths[i], pdfLineHeight, s.tr(header), "B", 0, "L", false, 0, "")
+	}
+	s.pdf.Ln(-1)
+}
+
+func (s *sheetPDF) row(widths []float64, values []string) {
+	x := s.pdf.GetX()
+	s.pdf.SetFont("Helvetica", "", 9)
+	for i, value := range values {
+		s.pdf.CellFormat(widths[i], pdfLineHeight, s.tr(value), "", 0, "L", false, 0, "")
+	}
+	s.pdf.Ln(-1)
+	s.pdf.SetX(x)
+}
+
+func (s *sheetPDF) textBlock(label, value string, width float64) {
+	x := s.pdf.GetX()
+	s.pdf.SetFont("Helvetica", "B", 8)
+	s.pdf.CellFormat(width, pdfLineHeight, s.tr(strings.ToUpper(label)), "B", 1, "L", false, 0, "")
+	s.pdf.SetX(x)
+	s.pdf.SetFont("Helvetica", "", 9)
+	if value == "" {
+		value = " "
+	}
+	s.pdf.MultiCell(width, pdfLineHeight, s.tr(value), "", "L", false)
+	s.pdf.Ln(3)
+	s.pdf.SetX(x)
+}
+
+// ------------------------
+// Formatting helpers
+// ------------------------
+func titleCase(value string) string {
+	words := strings.Fields(value)
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+	}
+	return strings.Join(words, " ")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func bubbles(count int) string {
+	marks := ""
+	for i := 0; i < 3; i++ {
+		if i < count {
+			marks += "X"
+		} else {
+			marks += "o"
+		}
+	}
+	return marks
+}
@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"dnd-character-sheet/models"
+	"strings"
+)
+
+// cantripsKnownBase is the number of cantrips a class knows at 1st level. Every
+// class learns one more at 4th and at 10th level.
+var cantripsKnownBase = map[string]int{
+	"bard":     2,
+	"cleric":   3,
+	"druid":    2,
+	"sorcerer": 4,
+	"warlock":  2,
+	"wizard":   3,
+}
+
+// SpellsKnownByLevel lists, per class level, how many spells of 1st level
+// and higher the classes that learn their spells know.
+var SpellsKnownByLevel = map[string][]int{
+	"bard":     {4, 5, 6, 7, 8, 9, 10, 11, 12, 14, 15, 15, 16, 18, 19, 19, 20, 22, 22, 22},
+	"ranger":   {0, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11},
+	"sorcerer": {2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 12, 13, 13, 14, 14, 15, 15, 15, 15},
+	"warlock":  {2, 3, 4, 5, 6, 7, 8, 9, 10, 10, 11, 11, 12, 12, 13, 13, 14, 14, 15, 15},
+}
+
+// SpellLimits is how many spells a character may have at its level.
+type SpellLimits struct {
+	Cantrips int
+	// Spells counts spells of 1st level and higher: the spells known, or for
+	// classes that prepare their spells, the spells prepared.
+	Spells   int
+	Prepared bool
+	// Spellbook is how many spells a wizard writes into the spellbook for
+	// free: six at 1st level and two more each level. Zero for other classes.
+	Spellbook int
+}
+
+// SpellLimitsFor works out the limits of the character's class and level.
+// Prepared casters prepare their spellcasting modifier plus their level in
+// spells, paladins plus half their level, and always at least one.
+func SpellLimitsFor(c models.Character) SpellLimits {
+	class := strings.ToLower(c.Class)
+	level := min(max(c.Level, 1), 20)
+
+	var limits SpellLimits
+	if base, ok := cantripsKnownBase[class]; ok {
+		limits.Cantrips = base
+		if level >= 4 {
+			limits.Cantrips++
+		}
+		if level >= 10 {
+			limits.Cantrips++
+		}
+	}
+
+	if known, ok := SpellsKnownByLevel[class]; ok {
+		limits.Spells = known[level-1]
+		return limits
+	}
+	if !PreparedCasters[class] {
+		return limits
+	}
+
+	limits.Prepared = true
+	if class == "wizard" {
+		limits.Spellbook = 6 + 2*(level-1)
+	}
+	if class == "paladin" {
+		if level < 2 {
+			return limits
+		}
+		level /= 2
+	}
+	modifier := c.Abilities.Modifier(models.SpellcastingClasses[class])
+	limits.Spells = max(modifier+level, 1)
+	return limits
+}
+
+// maxSpellLevel is the highest spell level the character has slots for.
+func maxSpellLevel(c models.Character) int {
+	highest := 0
+	for level, slots := range c.SpellSlots {
+		if level > highest && slots > 0 {
+			highest = level
+		}
+	}
+	return highest
+}
+
+// checkCanLearn returns an error when learning the spell would take the
+// character past its cantrips or spells known, or a wizard past the free
+// spells of its spellbook, or the spell is of a level it has no slots for.
+func checkCanLearn(c models.Character, spell models.Spell) error {
+	limits := SpellLimitsFor(c)
+	if spell.Level == 0 {
+		if known := countSpells(c, func(s models.Spell) bool { return s.Level == 0 }); known >= limits.Cantrips {
+			return invalidf("%s already knows %d cantrips, the most a level %d %s can know", c.Name, known, c.Level, c.Class)
+		}
+		return nil
+	}
+	if err := checkSpellLevel(c, spell); err != nil {
+		return err
+	}
+	if limits.Spellbook > 0 {
+		if written := countSpells(c, func(s models.Spell) bool { return s.Level > 0 && !s.Copied }); written >= limits.Spellbook {
+			return invalidf("%s already has %d spells in the spellbook, the most a level %d %s adds without copying", c.Name, written, c.Level, c.Class)
+		}
+		return nil
+	}
+	if known := countSpells(c, func(s models.Spell) bool { return s.Level > 0 }); known >= limits.Spells {
+		return invalidf("%s already knows %d spells, the most a level %d %s can know", c.Name, known, c.Level, c.Class)
+	}
+	return nil
+}
+
+// checkCanPrepare returns an error when preparing the spell would take the
+// character past the number of spells it can prepare, or the spell is of a
+// level it has no slots for.
+func checkCanPrepare(c models.Character, spell models.Spell) error {
+	if err := checkSpellLevel(c, spell); err != nil {
+		return err
+	}
+	limits := SpellLimitsFor(c)
+	prepared := countSpells(c, func(s models.Spell) bool {
+		return s.Level > 0 && s.Prepared && !strings.EqualFold(s.Name, spell.Name)
+	})
+	if prepared >= limits.Spells {
+		return invalidf("%s already has %d spells prepared, the most a level %d %s with %s %+d can prepare",
+			c.Name, prepared, c.Level, c.Class, c.SpellcastingAbility, c.Abilities.Modifier(c.SpellcastingAbility))
+	}
+	return nil
+}
+
+func checkSpellLevel(c models.Character, spell models.Spell) error {
+	highest := maxSpellLevel(c)
+	if highest == 0 {
+		return invalidf("%s has no spell slots yet", c.Name)
+	}
+	if spell.Level > highest {
+		return invalidf("%s is a level %d spell, but %s only has spell slots up to level %d", spell.Name, spell.Level, c.Name, highest)
+	}
+	return nil
+}
+
+func countSpells(c models.Character, match func(models.Spell) bool) int {
+	count := 0
+	for _, spell := range c.Spells {
+		if match(spell) {
+			count++
+		}
+	}
+	return count
+}
+
+// checkSpellChanges applies the rules of learning and preparing spells to a
+// spell list replaced as a whole, as by the API. Spells the character already
+// had, prepared or not, are accepted as they were; new spells must be class
+// spells of a level it has slots for, and no count may grow past its limit.
+func checkSpellChanges(before, after models.Character) error {
+	had := map[string]models.Spell{}
+	for _, spell := range before.Spells {
+		had[strings.ToLower(spell.Name)] = spell
+	}
+	for _, spell := range after.Spells {
+		old, ok := had[strings.ToLower(spell.Name)]
+		if ok && (old.Prepared || !spell.Prepared) {
+			continue
+		}
+		listed := FindSpellByName(spell.Name)
+		if listed == nil {
+			return invalidf("spell '%s' not found in spell list", spell.Name)
+		}
+		if !canUseSpell(after.Class, listed.Name) {
+			return invalidf("%s is not a %s spell", spell.Name, after.Class)
+		}
+		if listed.Level > 0 {
+			if err := checkSpellLevel(after, *listed); err != nil {
+				return err
+			}
+		}
+	}
+
+	type spellCount struct {
+		what  string
+		match func(models.Spell) bool
+		limit int
+	}
+	limits := SpellLimitsFor(after)
+	counts := []spellCount{{"cantrips", func(s models.Spell) bool { return s.Level == 0 }, limits.Cantrips}}
+	if limits.Prepared {
+		counts = append(counts, spellCount{"spells prepared", func(s models.Spell) bool { return s.Level > 0 && s.Prepared }, limits.Spells})
+	} else {
+		counts = append(counts, spellCount{"spells known", func(s models.Spell) bool { return s.Level > 0 }, limits.Spells})
+	}
+	if limits.Spellbook > 0 {
+		counts = append(counts, spellCount{"spells in the spellbook", func(s models.Spell) bool { return s.Level > 0 && !s.Copied }, limits.Spellbook})
+	}
+	for _, count := range counts {
+		n := countSpells(after, count.match)
+		if n > count.limit && n > countSpells(before, count.match) {
+			return invalidf("%s would have %d %s, the most a level %d %s can have is %d", after.Name, n, count.what, after.Level, after.Class, count.limit)
+		}
+	}
+	return nil
+}
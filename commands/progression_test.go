@@ -0,0 +1,177 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"dnd-character-sheet/models"
+)
+
+func TestSpellLimitsFor(t *testing.T) {
+	for _, test := range []struct {
+		class string
+		level int
+		score int
+		want  SpellLimits
+	}{
+		{"wizard", 1, 16, SpellLimits{Cantrips: 3, Spells: 4, Prepared: true, Spellbook: 6}},
+		{"wizard", 10, 16, SpellLimits{Cantrips: 5, Spells: 13, Prepared: true, Spellbook: 24}},
+		{"cleric", 4, 14, SpellLimits{Cantrips: 4, Spells: 6, Prepared: true}},
+		{"druid", 1, 8, SpellLimits{Cantrips: 2, Spells: 1, Prepared: true}},
+		{"paladin", 1, 16, SpellLimits{Prepared: true}},
+		{"paladin", 5, 16, SpellLimits{Spells: 5, Prepared: true}},
+		{"bard", 3, 10, SpellLimits{Cantrips: 2, Spells: 6}},
+		{"sorcerer", 1, 10, SpellLimits{Cantrips: 4, Spells: 2}},
+		{"warlock", 4, 10, SpellLimits{Cantrips: 3, Spells: 5}},
+		{"ranger", 1, 10, SpellLimits{}},
+		{"ranger", 2, 10, SpellLimits{Spells: 2}},
+		{"fighter", 5, 10, SpellLimits{}},
+	} {
+		c := models.Character{Class: test.class, Level: test.level}
+		c.Abilities = newCaster(test.class, test.level, test.score).Abilities
+		if got := SpellLimitsFor(c); got != test.want {
+			t.Errorf("level %d %s with %d: %+v, want %+v", test.level, test.class, test.score, got, test.want)
+		}
+	}
+}
+
+func TestCheckCanLearn(t *testing.T) {
+	cantrips := classSpells(t, "bard", 0, 3)
+	firstLevel := classSpells(t, "bard", 1, 5)
+	secondLevel := classSpells(t, "bard", 2, 1)
+
+	bard := newCaster("bard", 1, 10)
+	bard.Spells = cantrips[:1]
+	if err := checkCanLearn(bard, cantrips[1]); err != nil {
+		t.Errorf("second cantrip: %v", err)
+	}
+	bard.Spells = cantrips[:2]
+	if err := checkCanLearn(bard, cantrips[2]); err == nil {
+		t.Error("a level 1 bard learned a third cantrip")
+	}
+
+	bard.Spells = firstLevel[:3]
+	if err := checkCanLearn(bard, firstLevel[3]); err != nil {
+		t.Errorf("fourth spell: %v", err)
+	}
+	bard.Spells = firstLevel[:4]
+	if err := checkCanLearn(bard, firstLevel[4]); err == nil {
+		t.Error("a level 1 bard learned a fifth spell")
+	}
+	bard.Spells = nil
+	if err := checkCanLearn(bard, secondLevel[0]); err == nil || !strings.Contains(err.Error(), "up to level 1") {
+		t.Errorf("level 2 spell at level 1: %v", err)
+	}
+}
+
+func TestCheckCanLearnSpellbook(t *testing.T) {
+	spells := classSpells(t, "wizard", 1, 7)
+	wizard := newCaster("wizard", 1, 16)
+	wizard.Spells = append([]models.Spell(nil), spells[:6]...)
+	if err := checkCanLearn(wizard, spells[6]); err == nil {
+		t.Error("a level 1 wizard wrote a seventh free spell")
+	}
+
+	// Copied spells are paid for and don't use up the free ones.
+	wizard.Spells[0].Copied = true
+	if err := checkCanLearn(wizard, spells[6]); err != nil {
+		t.Errorf("with a copied spell: %v", err)
+	}
+}
+
+func TestCheckCanPrepare(t *testing.T) {
+	spells := classSpells(t, "cleric", 1, 4)
+	cleric := newCaster("cleric", 1, 14)
+	for _, spell := range spells[:3] {
+		spell.Prepared = true
+		cleric.Spells = append(cleric.Spells, spell)
+	}
+
+	if err := checkCanPrepare(cleric, spells[3]); err == nil {
+		t.Error("a level 1 cleric with Wisdom 14 prepared four spells")
+	}
+	if err := checkCanPrepare(cleric, spells[0]); err != nil {
+		t.Errorf("preparing a prepared spell again: %v", err)
+	}
+	if err := checkCanPrepare(cleric, classSpells(t, "cleric", 2, 1)[0]); err == nil {
+		t.Error("a level 1 cleric prepared a level 2 spell")
+	}
+}
+
+func TestCheckSpellChanges(t *testing.T) {
+	before := newCaster("wizard", 5, 16)
+	before.Spells = append(classSpells(t, "wizard", 0, 6), classSpells(t, "wizard", 1, 2)...)
+
+	for _, test := range []struct {
+		name    string
+		change  func(c *models.Character)
+		wantErr string
+	}{
+		{"unchanged", func(c *models.Character) {}, ""},
+		{"fewer cantrips, still over the limit", func(c *models.Character) {
+			c.Spells = c.Spells[1:]
+		}, ""},
+		{"prepare a spell", func(c *models.Character) {
+			c.Spells[6].Prepared = true
+		}, ""},
+		{"too high a level", func(c *models.Character) {
+			c.Spells = append(c.Spells, models.Spell{Name: "wish", Level: 9, Prepared: true})
+		}, "level 9"},
+		{"another class's spell", func(c *models.Character) {
+			c.Spells = append(c.Spells, models.Spell{Name: "cure wounds", Level: 1})
+		}, "not a wizard spell"},
+		{"unknown spell", func(c *models.Character) {
+			c.Spells = append(c.Spells, models.Spell{Name: "summon pizza", Level: 1})
+		}, "not found"},
+		{"another cantrip", func(c *models.Character) {
+			c.Spells = append(c.Spells, classSpells(t, "wizard", 0, 7)[6])
+		}, "7 cantrips"},
+		{"too many prepared", func(c *models.Character) {
+			for _, spell := range classSpells(t, "wizard", 1, 9) {
+				spell.Prepared = true
+				if i := spellIndex(*c, spell.Name); i >= 0 {
+					c.Spells[i] = spell
+				} else {
+					c.Spells = append(c.Spells, spell)
+				}
+			}
+		}, "spells prepared"},
+	} {
+		after := before.Clone()
+		after.Spells = append([]models.Spell(nil), before.Spells...)
+		test.change(&after)
+		err := checkSpellChanges(before, after)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("%s: error %v, want one about %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestLearnSpellChecksLimits(t *testing.T) {
+	useTempStorage(t)
+	bard := newCaster("bard", 1, 10)
+	bard.Spells = classSpells(t, "bard", 1, 4)
+	saveTestCharacter(t, bard)
+
+	if _, err := LearnSpell(bard.Name, classSpells(t, "bard", 1, 5)[4].Name); err == nil {
+		t.Error("LearnSpell let a level 1 bard learn a fifth spell")
+	}
+	if _, err := LearnSpell(bard.Name, classSpells(t, "bard", 0, 1)[0].Name); err != nil {
+		t.Errorf("learning a cantrip: %v", err)
+	}
+}
+
+func TestLearnSpellIgnoresCase(t *testing.T) {
+	useTempStorage(t)
+	bard := newCaster("bard", 1, 10)
+	known := classSpells(t, "bard", 1, 1)[0]
+	bard.Spells = []models.Spell{{Name: strings.ToUpper(known.Name), Level: 1}}
+	saveTestCharacter(t, bard)
+
+	if _, err := LearnSpell(bard.Name, strings.ToLower(known.Name)); err == nil {
+		t.Errorf("LearnSpell learned %s a second time", known.Name)
+	}
+}
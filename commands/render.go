@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"dnd-character-sheet/models"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SheetRenderer writes a character sheet in a particular output format.
+type SheetRenderer interface {
+	Render(w io.Writer, c models.Character) error
+}
+
+var SheetRenderers = map[string]SheetRenderer{
+	"text":     TextRenderer{},
+	"markdown": MarkdownRenderer{},
+	"md":       MarkdownRenderer{},
+}
+
+func RendererFormats() []string {
+	formats := make([]string, 0, len(SheetRenderers))
+	for format := range SheetRenderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// MarkdownRenderer renders a sheet for markdown wikis and chat.
+type MarkdownRenderer struct{}
+
+func (MarkdownRenderer) Render(w io.Writer, c models.Character) error {
+	fmt.Fprintf(w, "# %s\n\n", markdownEscape(c.Name))
+
+	fmt.Fprintln(w, "| Class & Level | Race | Background | Alignment | Player | XP |")
+	fmt.Fprintln(w, "|---|---|---|---|---|---|")
+	fmt.Fprintf(w, "| %s %d | %s | %s | %s | %s | %d |\n\n",
+		markdownEscape(titleCase(c.Class)), c.Level,
+		markdownEscape(titleCase(c.Race)),
+		markdownEscape(titleCase(c.Background)),
+		markdownEscape(orDash(c.Alignment)),
+		markdownEscape(orDash(c.PlayerName)),
+		c.ExperiencePoints)
+
+	fmt.Fprintln(w, "## Abilities")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Ability | Score | Modifier | Saving Throw |")
+	fmt.Fprintln(w, "|---|---:|---:|---:|")
+	for _, ability := range abilityOrder {
+		save := models.FormatModifier(c.SavingThrows[ability])
+		if c.HasSavingThrowProficiency(ability) {
+			save = "**" + save + "**"
+		}
+		fmt.Fprintf(w, "| %s | %d | %s | %s |\n", ability, c.Abilities.Score(ability), models.FormatModifier(c.Abilities.Modifier(ability)), save)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "**Proficiency bonus:** %s\n\n", models.FormatModifier(c.ProficiencyBonus))
+
+	fmt.Fprintln(w, "## Skills")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Skill | Ability | Bonus | Proficiency | Notes |")
+	fmt.Fprintln(w, "|---|---|---:|:---:|---|")
+	skills := make([]string, 0, len(models.SkillAbilities))
+	for skill := range models.SkillAbilities {
+		skills = append(skills, skill)
+	}
+	sort.Strings(skills)
+	for _, skill := range skills {
+		notes := ""
+		if note, ok := c.SkillAdvantages[skill]; ok {
+			notes = "Advantage"
+			if note != "" {
+				notes += " (" + markdownEscape(note) + ")"
+			}
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", skill, models.SkillAbilities[skill][:3],
+			models.FormatModifier(c.Skills[skill]), proficiencyMarker(c.SkillProficiencyLevel(skill)), notes)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "## Combat")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| AC | Initiative | Speed | HP | Passive Perception | Passive Investigation | Passive Insight |")
+	fmt.Fprintln(w, "|---:|---:|---:|---:|---:|---:|---:|")
+	fmt.Fprintf(w, "| %d | %s | %d ft | %d / %d | %d | %d | %d |\n\n",
+		c.ArmorClass, models.FormatModifier(c.Initiative), c.Speed,
+		c.CurrentHitPoints, c.MaxHitPoints,
+		c.PassivePerception, c.PassiveInvestigation, c.PassiveInsight)
+
+	fmt.Fprintln(w, "## Equipment")
+	fmt.Fprintln(w)
+	hasEquipment := false
+	if c.Equipment.MainHand != nil {
+		fmt.Fprintf(w, "- **Main hand:** %s\n", markdownEscape(c.Equipment.MainHand.Name))
+		hasEquipment = true
+	}
+	if c.Equipment.OffHand != nil {
+		fmt.Fprintf(w, "- **Off hand:** %s\n", markdownEscape(c.Equipment.OffHand.Name))
+		hasEquipment = true
+	}
+	if c.Equipment.Armor != nil {
+		fmt.Fprintf(w, "- **Armor:** %s (AC %d)\n", markdownEscape(c.Equipment.Armor.Name), c.Equipment.Armor.ArmorClass)
+		hasEquipment = true
+	}
+	if c.Equipment.Shield != nil {
+		fmt.Fprintf(w, "- **Shield:** %s (+%d AC)\n", markdownEscape(c.Equipment.Shield.Name), c.Equipment.Shield.ArmorClass)
+		hasEquipment = true
+	}
+	if !hasEquipment {
+		fmt.Fprintln(w, "_Nothing equipped._")
+	}
+	fmt.Fprintln(w)
+
+	if c.SpellcastingAbility == "" && len(c.Spells) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "## Spellcasting")
+	fmt.Fprintln(w)
+	if c.SpellcastingAbility != "" {
+		fmt.Fprintf(w, "**Ability:** %s · **Save DC:** %d · **Attack bonus:** %s\n\n",
+			c.SpellcastingAbility, c.SpellSaveDC, models.FormatModifier(c.SpellAttackBonus))
+	}
+
+	spellsByLevel := map[int][]models.Spell{}
+	for _, spell := range c.Spells {
+		spellsByLevel[spell.Level] = append(spellsByLevel[spell.Level], spell)
+	}
+	levels := make([]int, 0, len(spellsByLevel))
+	for lvl := range spellsByLevel {
+		levels = append(levels, lvl)
+	}
+	sort.Ints(levels)
+
+	for _, lvl := range levels {
+		if lvl == 0 {
+			fmt.Fprintln(w, "### Cantrips")
+		} else if slots, ok := c.SpellSlots[lvl]; ok {
+			fmt.Fprintf(w, "### Level %d (%d slots)\n", lvl, slots)
+		} else {
+			fmt.Fprintf(w, "### Level %d\n", lvl)
+		}
+		fmt.Fprintln(w)
+		for _, spell := range spellsByLevel[lvl] {
+			line := markdownEscape(spell.Name)
+			if spell.Prepared {
+				line = "**" + line + "** (prepared)"
+			}
+			if spell.School != "" {
+				line += " — " + markdownEscape(spell.School)
+			}
+			fmt.Fprintf(w, "- %s\n", line)
+			if spell.HasDetails() {
+				writeMarkdownSpellCard(w, spell)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	return nil
+}
+
+// writeMarkdownSpellCard writes the card as paragraphs of the spell's list
+// item.
+func writeMarkdownSpellCard(w io.Writer, spell models.Spell) {
+	facts := spellCardFacts(spell)
+	for i, fact := range facts {
+		name, value, _ := strings.Cut(fact, ": ")
+		facts[i] = "*" + name + ":* " + markdownEscape(value)
+	}
+	if spell.Ritual {
+		facts = append(facts, "*Ritual*")
+	}
+	fmt.Fprintf(w, "\n  %s\n", strings.Join(facts, " · "))
+	for _, paragraph := range strings.Split(spell.Description, "\n\n") {
+		fmt.Fprintf(w, "\n  %s\n", markdownEscape(paragraph))
+	}
+	if spell.HigherLevel != "" {
+		fmt.Fprintf(w, "\n  ***At higher levels.*** %s\n", markdownEscape(spell.HigherLevel))
+	}
+	fmt.Fprintln(w)
+}
+
+func proficiencyMarker(level models.ProficiencyLevel) string {
+	switch level {
+	case models.HalfProficient:
+		return "½"
+	case models.Proficient:
+		return "✓"
+	case models.Expertise:
+		return "✓✓"
+	}
+	return ""
+}
+
+var markdownReplacer = strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`")
+
+func markdownEscape(value string) string {
+	return markdownReplacer.Replace(value)
+}
@@ -1,11 +1,12 @@
 package commands
 
 import (
+	"dnd-character-sheet/api"
 	"dnd-character-sheet/models"
 	"dnd-character-sheet/storage"
 	"encoding/csv"
 	"fmt"
-	"os"
+	"io/fs"
 	"strconv"
 	"strings"
 )
@@ -15,6 +16,7 @@ var SpellcastingClasses = map[string]bool{
 	"cleric":   true,
 	"druid":    true,
 	"paladin":  true,
+	"ranger":   true,
 	"sorcerer": true,
 	"warlock":  true,
 	"wizard":   true,
@@ -27,12 +29,28 @@ var PreparedCasters = map[string]bool{
 	"wizard":  true,
 }
 
+// RitualCasters can cast spells with the ritual tag as rituals, without
+// using a spell slot.
+var RitualCasters = map[string]bool{
+	"bard":   true,
+	"cleric": true,
+	"druid":  true,
+	"wizard": true,
+}
+
+// What copying a spell into a wizard's spellbook takes, per spell level.
+const (
+	CopySpellGoldPerLevel  = 50
+	CopySpellHoursPerLevel = 2
+)
+
 var SpellList []models.Spell
 
 var SpellClasses = map[string][]string{}
 
-func LoadSpellsFromCSV(filePath string) error {
-	file, err := os.Open(filePath)
+// LoadSpellsFromCSV reads the spell catalog at filePath in fsys.
+func LoadSpellsFromCSV(fsys fs.FS, filePath string) error {
+	file, err := fsys.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open spells file: %v", err)
 	}
@@ -58,9 +76,15 @@ func LoadSpellsFromCSV(filePath string) error {
 			classes[i] = strings.TrimSpace(classes[i])
 		}
 
+		ritual := false
+		if len(row) > 3 {
+			ritual, _ = strconv.ParseBool(strings.TrimSpace(row[3]))
+		}
+
 		SpellList = append(SpellList, models.Spell{
-			Name:  name,
-			Level: level,
+			Name:   name,
+			Level:  level,
+			Ritual: ritual,
 		})
 		SpellClasses[name] = classes
 	}
@@ -91,141 +115,410 @@ func FindSpellsForClass(class string) []models.Spell {
 	return spells
 }
 
-func GiveStartingSpells(character *models.Character) error {
-	if character.CanPrepareSpells {
-		spells := FindSpellsForClass(character.Class)
-		for _, s := range spells {
-			if s.Level == 0 {
-				character.Spells = append(character.Spells, models.Spell{
-					Name:     s.Name,
-					Level:    s.Level,
-					Prepared: false,
-				})
+// GiveStartingSpells sets up spell slots and gives prepared casters as many
+// of their class cantrips as they can know, in spell list order. Wizards get
+// the free spells of their spellbook. The caller saves the character.
+func GiveStartingSpells(character *models.Character) {
+	SetupSpellcasting(character)
+	if !character.CanPrepareSpells {
+		return
+	}
+	cantrips := SpellLimitsFor(*character).Cantrips - countSpells(*character, func(s models.Spell) bool { return s.Level == 0 })
+	for _, s := range FindSpellsForClass(character.Class) {
+		if cantrips <= 0 {
+			break
+		}
+		if s.Level != 0 || spellIndex(*character, s.Name) >= 0 {
+			continue
+		}
+		character.Spells = append(character.Spells, withSpellCard(models.Spell{
+			Name:     s.Name,
+			Level:    s.Level,
+			Prepared: false,
+		}))
+		cantrips--
+	}
+	fillSpellbook(character)
+}
+
+// fillSpellbook writes the free spells of a wizard's spellbook the character
+// doesn't have yet: six at 1st level and two for each level after, each time
+// of the highest level it had slots for then. Spells are picked in spell list
+// order and the book is never filled past SpellLimitsFor.
+func fillSpellbook(character *models.Character) {
+	if !hasSpellbook(*character) {
+		return
+	}
+	written := countSpells(*character, func(s models.Spell) bool { return s.Level > 0 && !s.Copied })
+	classSpells := FindSpellsForClass(character.Class)
+	for level := 1; level <= character.Level; level++ {
+		then := models.Character{Class: character.Class, Level: level, Abilities: character.Abilities}
+		SetupSpellcasting(&then)
+		free := SpellLimitsFor(then).Spellbook
+		for spellLevel := maxSpellLevel(then); spellLevel > 0 && written < free; spellLevel-- {
+			for _, s := range classSpells {
+				if written >= free {
+					break
+				}
+				if s.Level != spellLevel || spellIndex(*character, s.Name) >= 0 {
+					continue
+				}
+				character.Spells = append(character.Spells, withSpellCard(models.Spell{
+					Name:   s.Name,
+					Level:  s.Level,
+					Ritual: s.Ritual,
+				}))
+				written++
 			}
 		}
 	}
-	SetupSpellcasting(character)
-	return storage.SaveCharacter(*character)
 }
 
-func LearnSpell(characterName, spellName string) error {
+func LearnSpell(characterName, spellName string) (string, error) {
 	characters, err := storage.LoadCharacters()
 	if err != nil {
-		return err
+		return "", err
 	}
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character \"%s\" not found", characterName)
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 	if !SpellcastingClasses[character.Class] {
-		return fmt.Errorf("this class can't cast spells")
-	}
-	if character.CanPrepareSpells {
-		return fmt.Errorf("this class prepares spells and can't learn them")
+		return "", invalidf("this class can't cast spells")
 	}
 
 	spell := FindSpellByName(spellName)
 	if spell == nil {
-		return fmt.Errorf("spell '%s' not found in spell list", spellName)
+		return "", invalidf("spell '%s' not found in spell list", spellName)
 	}
-
-	valid := false
-	for _, c := range SpellClasses[spell.Name] {
-		if c == strings.ToLower(character.Class) {
-			valid = true
-			break
-		}
+	if character.CanPrepareSpells && spell.Level > 0 && !hasSpellbook(character) {
+		return "", invalidf("this class prepares spells and can only learn cantrips")
 	}
-	if !valid {
-		return fmt.Errorf("%s cannot learn %s", character.Class, spellName)
+
+	if !canUseSpell(character.Class, spell.Name) {
+		return "", invalidf("%s cannot learn %s", character.Class, spellName)
 	}
 
-	for _, s := range character.Spells {
-		if s.Name == spell.Name {
-			return fmt.Errorf("character '%s' already knows spell '%s'", characterName, spell.Name)
-		}
+	if spellIndex(character, spell.Name) >= 0 {
+		return "", invalidf("character '%s' already knows spell '%s'", characterName, spell.Name)
+	}
+	if err := checkCanLearn(character, *spell); err != nil {
+		return "", err
 	}
 
-	character.Spells = append(character.Spells, models.Spell{
+	character.Spells = append(character.Spells, withSpellCard(models.Spell{
 		Name:     spell.Name,
 		Level:    spell.Level,
 		Prepared: false,
-	})
-	if err := storage.SaveCharacter(character); err != nil {
-		return err
+		Ritual:   spell.Ritual,
+	}))
+	if err := saveCharacterChange("learn-spell", before, character); err != nil {
+		return "", err
 	}
-	fmt.Printf("Learned spell %s\n", spell.Name)
-	return nil
+	if hasSpellbook(character) && spell.Level > 0 {
+		return fmt.Sprintf("Wrote %s into the spellbook", spell.Name), nil
+	}
+	return fmt.Sprintf("Learned spell %s", spell.Name), nil
 }
 
-func PrepareSpell(characterName, spellName string, spellLevel int) error {
+// PrepareSpell prepares the named spells from the character's class list,
+// or for wizards from their spellbook. With replace, every other spell is
+// unprepared first, as when choosing the day's spells after a long rest. The
+// spells' levels are kept as they are; the slot a spell uses is chosen when
+// it is cast.
+func PrepareSpell(characterName string, spellNames []string, replace bool) (string, error) {
 	characters, err := storage.LoadCharacters()
 	if err != nil {
-		return err
+		return "", err
 	}
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf(`character "%s" not found`, characterName)
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 	if !SpellcastingClasses[character.Class] {
-		return fmt.Errorf("this class can't cast spells")
+		return "", invalidf("this class can't cast spells")
 	}
 	if !character.CanPrepareSpells {
-		return fmt.Errorf("this class learns spells and can't prepare them")
+		return "", invalidf("this class learns spells and can't prepare them")
+	}
+	if len(spellNames) == 0 && !replace {
+		return "", invalidf("no spells to prepare")
 	}
 
-	spellIndex := -1
-	for i, s := range character.Spells {
-		if s.Name == spellName {
-			spellIndex = i
-			break
+	if replace {
+		for i := range character.Spells {
+			if character.Spells[i].Level > 0 {
+				character.Spells[i].Prepared = false
+			}
 		}
 	}
 
-	if spellIndex == -1 && character.CanPrepareSpells {
-		classSpells := FindSpellsForClass(character.Class)
-		for _, s := range classSpells {
-			if s.Name == spellName {
-				spellIndex = -2
-				break
-			}
+	var prepared []string
+	for _, spellName := range spellNames {
+		spell := FindSpellByName(spellName)
+		if spell == nil {
+			return "", invalidf("spell '%s' not found in spell list", spellName)
+		}
+		if spell.Level == 0 {
+			return "", invalidf("%s is a cantrip and is always ready, learn it instead", spell.Name)
+		}
+		if !canUseSpell(character.Class, spell.Name) {
+			return "", invalidf("spell '%s' not available for class '%s'", spell.Name, character.Class)
 		}
-		if spellIndex == -1 {
-			return fmt.Errorf("spell '%s' not available for class '%s'", spellName, character.Class)
+		i := spellIndex(character, spell.Name)
+		if i < 0 && hasSpellbook(character) {
+			return "", invalidf("%s is not in %s's spellbook", spell.Name, character.Name)
 		}
+		if err := checkCanPrepare(character, *spell); err != nil {
+			return "", err
+		}
+
+		if i >= 0 {
+			character.Spells[i].Prepared = true
+		} else {
+			character.Spells = append(character.Spells, withSpellCard(models.Spell{
+				Name:     spell.Name,
+				Level:    spell.Level,
+				Prepared: true,
+				Ritual:   spell.Ritual,
+			}))
+		}
+		prepared = append(prepared, spell.Name)
+	}
+
+	if err := saveCharacterChange("prepare-spell", before, character); err != nil {
+		return "", err
+	}
+	if len(prepared) == 0 {
+		return "Unprepared all spells", nil
+	}
+	return fmt.Sprintf("Prepared %s", strings.Join(prepared, ", ")), nil
+}
+
+// UnprepareSpell unprepares the named spells. They stay on the character's
+// spell list.
+func UnprepareSpell(characterName string, spellNames []string) (string, error) {
+	characters, err := storage.LoadCharacters()
+	if err != nil {
+		return "", err
+	}
+	character, exists := characters[characterName]
+	if !exists {
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
+	}
+	before := character.Clone()
+	if !character.CanPrepareSpells {
+		return "", invalidf("this class learns spells and can't prepare them")
+	}
+	if len(spellNames) == 0 {
+		return "", invalidf("no spells to unprepare")
+	}
+
+	var names []string
+	for _, spellName := range spellNames {
+		i := spellIndex(character, spellName)
+		if i < 0 || !character.Spells[i].Prepared {
+			return "", invalidf("spell '%s' is not prepared", spellName)
+		}
+		character.Spells[i].Prepared = false
+		names = append(names, character.Spells[i].Name)
+	}
+
+	if err := saveCharacterChange("unprepare-spell", before, character); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Unprepared %s", strings.Join(names, ", ")), nil
+}
+
+// CastSpell casts a known or prepared spell with a slot of slotLevel, or when
+// slotLevel is 0 the lowest slot left of the spell's level or higher, which
+// for warlocks is their pact slot. A higher slot upcasts the spell.
+// Cantrips don't use a slot, and neither do rituals: classes with ritual
+// casting can cast a ritual spell they have prepared, or wizards any ritual
+// in their spellbook, ten minutes more slowly.
+func CastSpell(characterName, spellName string, slotLevel int, ritual bool) (string, error) {
+	characters, err := storage.LoadCharacters()
+	if err != nil {
+		return "", err
+	}
+	character, exists := characters[characterName]
+	if !exists {
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
+	}
+	before := character.Clone()
+
+	i := spellIndex(character, spellName)
+	if i < 0 {
+		return "", invalidf("character '%s' doesn't know spell '%s'", characterName, spellName)
+	}
+	spell := character.Spells[i]
+	if spell.Level == 0 {
+		return fmt.Sprintf("%s casts %s", character.Name, spell.Name), nil
+	}
+	if ritual {
+		return castRitual(character, spell, slotLevel)
+	}
+	if character.CanPrepareSpells && !spell.Prepared {
+		return "", invalidf("spell '%s' is not prepared", spell.Name)
+	}
+	if slotLevel == 0 {
+		slotLevel = lowestSlotLeft(character, spell.Level)
+		if slotLevel == 0 {
+			return "", invalidf("no spell slots of level %d or higher left", spell.Level)
+		}
+	}
+	if slotLevel < spell.Level {
+		return "", invalidf("%s is a level %d spell and can't be cast with a level %d slot", spell.Name, spell.Level, slotLevel)
+	}
+	remaining := character.RemainingSpellSlots(slotLevel)
+	if remaining == 0 {
+		return "", invalidf("no level %d spell slots left", slotLevel)
+	}
+
+	if character.SpellSlotsUsed == nil {
+		character.SpellSlotsUsed = map[int]int{}
+	}
+	character.SpellSlotsUsed[slotLevel]++
+	if err := saveCharacterChange("cast-spell", before, character); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s casts %s with a level %d slot (%d/%d left)", character.Name, spell.Name, slotLevel, remaining-1, character.SpellSlots[slotLevel]), nil
+}
+
+func castRitual(character models.Character, spell models.Spell, slotLevel int) (string, error) {
+	if !RitualCasters[strings.ToLower(character.Class)] {
+		return "", invalidf("%s can't cast rituals", character.Class)
+	}
+	if !isRitual(spell) {
+		return "", invalidf("%s is not a ritual", spell.Name)
+	}
+	if slotLevel != 0 && slotLevel != spell.Level {
+		return "", invalidf("rituals are cast at their own level, without a spell slot")
+	}
+	if character.CanPrepareSpells && !spell.Prepared && !hasSpellbook(character) {
+		return "", invalidf("spell '%s' is not prepared", spell.Name)
+	}
+	return fmt.Sprintf("%s casts %s as a ritual, taking 10 minutes longer", character.Name, spell.Name), nil
+}
+
+// isRitual reports whether the spell has the ritual tag, going by the spell
+// list for spells the character got before the tag was recorded.
+func isRitual(spell models.Spell) bool {
+	if spell.Ritual {
+		return true
+	}
+	listed := FindSpellByName(spell.Name)
+	return listed != nil && listed.Ritual
+}
+
+// CopySpell copies a wizard spell into the character's spellbook, from a
+// scroll or another wizard's book. It costs 50 gp and 2 hours per spell
+// level, and unlike the spells a wizard gains with levels isn't limited in
+// number.
+func CopySpell(characterName, spellName string) (string, error) {
+	characters, err := storage.LoadCharacters()
+	if err != nil {
+		return "", err
+	}
+	character, exists := characters[characterName]
+	if !exists {
+		return "", loadError(characterName, storage.ErrCharacterNotFound)
+	}
+	before := character.Clone()
+	if !hasSpellbook(character) {
+		return "", invalidf("only wizards keep a spellbook")
 	}
 
 	spell := FindSpellByName(spellName)
 	if spell == nil {
-		return fmt.Errorf("spell '%s' not found in spell list", spellName)
+		return "", invalidf("spell '%s' not found in spell list", spellName)
+	}
+	if spell.Level == 0 {
+		return "", invalidf("%s is a cantrip, cantrips aren't written in a spellbook", spell.Name)
+	}
+	if !canUseSpell(character.Class, spell.Name) {
+		return "", invalidf("%s is not a %s spell", spell.Name, character.Class)
+	}
+	if spellIndex(character, spell.Name) >= 0 {
+		return "", invalidf("%s is already in %s's spellbook", spell.Name, character.Name)
+	}
+	if err := checkSpellLevel(character, *spell); err != nil {
+		return "", err
+	}
+	cost := CopySpellGoldPerLevel * spell.Level
+	if character.GoldPieces < cost {
+		return "", invalidf("copying %s costs %d gp, but %s has only %d gp", spell.Name, cost, character.Name, character.GoldPieces)
 	}
 
-	if spellLevel < spell.Level {
-		return fmt.Errorf("the spell has higher level than the available spell slots")
+	character.GoldPieces -= cost
+	character.Spells = append(character.Spells, withSpellCard(models.Spell{
+		Name:   spell.Name,
+		Level:  spell.Level,
+		Ritual: spell.Ritual,
+		Copied: true,
+	}))
+	if err := saveCharacterChange("copy-spell", before, character); err != nil {
+		return "", err
 	}
-	if slots, ok := character.SpellSlots[spellLevel]; !ok || slots == 0 {
-		return fmt.Errorf("no available spell slots of level %d", spellLevel)
+	return fmt.Sprintf("Copied %s into the spellbook in %d hours for %d gp (%d gp left)", spell.Name, CopySpellHoursPerLevel*spell.Level, cost, character.GoldPieces), nil
+}
+
+// hasSpellbook reports whether the character prepares its spells from a
+// spellbook rather than the whole class list.
+func hasSpellbook(c models.Character) bool {
+	return SpellLimitsFor(c).Spellbook > 0
+}
+
+func spellIndex(c models.Character, name string) int {
+	for i, s := range c.Spells {
+		if strings.EqualFold(s.Name, name) {
+			return i
+		}
 	}
+	return -1
+}
 
-	if spellIndex == -2 {
-		character.Spells = append(character.Spells, models.Spell{
-			Name:     spell.Name,
-			Level:    spellLevel,
-			Prepared: true,
-		})
-	} else {
-		character.Spells[spellIndex].Prepared = true
-		character.Spells[spellIndex].Level = spellLevel
+// lowestSlotLeft is the lowest spell slot level from level up that the
+// character has slots left of, or 0 when it has none.
+func lowestSlotLeft(c models.Character, level int) int {
+	for ; level <= 9; level++ {
+		if c.RemainingSpellSlots(level) > 0 {
+			return level
+		}
 	}
+	return 0
+}
 
-	if err := storage.SaveCharacter(character); err != nil {
-		return err
+func canUseSpell(class, spellName string) bool {
+	for _, c := range SpellClasses[strings.ToLower(spellName)] {
+		if c == strings.ToLower(class) {
+			return true
+		}
 	}
-	fmt.Printf("Prepared spell %s\n", spellName)
-	return nil
+	return false
+}
+
+// withSpellCard fills in the spell card from the offline SRD catalog when the
+// spell is cached there. It never goes online.
+func withSpellCard(spell models.Spell) models.Spell {
+	cached, ok := api.CachedSpell(spell.Name)
+	if !ok {
+		return spell
+	}
+	card := cached.Spell()
+	card.Name = spell.Name
+	card.Level = spell.Level
+	card.Prepared = spell.Prepared
+	card.Copied = spell.Copied
+	return card
 }
 
+// SetupSpellcasting sets the character's spellcasting stats and spell slots
+// for its class and level.
 func SetupSpellcasting(c *models.Character) {
+	c.SetupSpellcasting()
 	class := strings.ToLower(c.Class)
 	if !SpellcastingClasses[class] {
 		c.SpellSlots = nil
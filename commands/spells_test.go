@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"testing"
+
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+func countByLevel(c models.Character) map[int]int {
+	counts := map[int]int{}
+	for _, spell := range c.Spells {
+		counts[spell.Level]++
+	}
+	return counts
+}
+
+func TestGiveStartingSpells(t *testing.T) {
+	for _, test := range []struct {
+		class string
+		level int
+		want  map[int]int
+	}{
+		{"wizard", 1, map[int]int{0: 3, 1: 6}},
+		{"wizard", 5, map[int]int{0: 4, 1: 8, 2: 4, 3: 2}},
+		{"cleric", 4, map[int]int{0: 4}},
+		{"druid", 1, map[int]int{0: 2}},
+		{"bard", 1, map[int]int{}},
+		{"fighter", 3, map[int]int{}},
+	} {
+		c := models.Character{Class: test.class, Level: test.level}
+		GiveStartingSpells(&c)
+		got := countByLevel(c)
+		for level := 0; level <= 9; level++ {
+			if got[level] != test.want[level] {
+				t.Errorf("level %d %s: %d spells of level %d, want %d", test.level, test.class, got[level], level, test.want[level])
+			}
+		}
+		for _, spell := range c.Spells {
+			if spell.Prepared {
+				t.Errorf("level %d %s starts with %s prepared", test.level, test.class, spell.Name)
+			}
+		}
+	}
+}
+
+func TestSetLevelFillsSpellbook(t *testing.T) {
+	wizard := models.Character{Class: "wizard", Level: 1}
+	GiveStartingSpells(&wizard)
+	copied := classSpells(t, "wizard", 1, 20)[19]
+	copied.Copied = true
+	wizard.Spells = append(wizard.Spells, copied)
+
+	if err := setLevel(&wizard, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := countByLevel(wizard); got[1] != 9 || got[2] != 2 {
+		t.Errorf("level 3 wizard has %v spells by level, want 8 free and 1 copied of level 1 and 2 of level 2", got)
+	}
+
+	if err := setLevel(&wizard, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := countByLevel(wizard); got[1] != 9 || got[2] != 2 {
+		t.Errorf("going down a level changed the spellbook to %v", got)
+	}
+	for _, level := range []int{0, 21} {
+		if err := setLevel(&wizard, level); err == nil {
+			t.Errorf("setLevel accepted level %d", level)
+		}
+	}
+}
+
+func TestCastSpellDefaultSlot(t *testing.T) {
+	useTempStorage(t)
+	warlock := newCaster("warlock", 3, 14)
+	warlock.Spells = classSpells(t, "warlock", 1, 1)
+	wizard := newCaster("wizard", 3, 14)
+	spell := classSpells(t, "wizard", 1, 1)[0]
+	spell.Prepared = true
+	wizard.Spells = []models.Spell{spell}
+	saveTestCharacter(t, warlock)
+	saveTestCharacter(t, wizard)
+
+	// Warlocks cast every spell with their pact slots, of level 2 at 3rd level.
+	if _, err := CastSpell(warlock.Name, warlock.Spells[0].Name, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := storage.GetCharacterByName(warlock.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SpellSlotsUsed[2] != 1 {
+		t.Errorf("warlock used slots %v, want one of level 2", stored.SpellSlotsUsed)
+	}
+
+	// A wizard out of level 1 slots moves on to level 2, then runs out.
+	for range wizard.SpellSlots[1] + wizard.SpellSlots[2] {
+		if _, err := CastSpell(wizard.Name, spell.Name, 0, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored, err = storage.GetCharacterByName(wizard.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.SpellSlotsUsed[1] != wizard.SpellSlots[1] || stored.SpellSlotsUsed[2] != wizard.SpellSlots[2] {
+		t.Errorf("wizard used slots %v, want every slot of %v", stored.SpellSlotsUsed, wizard.SpellSlots)
+	}
+	if _, err := CastSpell(wizard.Name, spell.Name, 0, false); err == nil {
+		t.Error("cast with every slot used")
+	}
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"dnd-character-sheet/models"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoStartingEquipment is returned by StartingEquipmentOptions when the
+// character can't take any of its class's starting equipment.
+var ErrNoStartingEquipment = errors.New("no starting equipment")
+
+// StartingLoadout is one way to take a class's starting equipment. The names
+// refer to the equipment catalog; empty slots stay empty.
+type StartingLoadout struct {
+	MainHand string
+	OffHand  string
+	Armor    string
+	Shield   string
+}
+
+func (l StartingLoadout) String() string {
+	var items []string
+	if l.MainHand != "" && l.MainHand == l.OffHand {
+		items = append(items, "two "+weaponDisplayName(l.MainHand)+"s")
+	} else {
+		for _, item := range []string{l.MainHand, l.OffHand} {
+			if item != "" {
+				items = append(items, weaponDisplayName(item))
+			}
+		}
+	}
+	if l.Armor != "" {
+		items = append(items, armorDisplayName(l.Armor))
+	}
+	if l.Shield != "" {
+		items = append(items, l.Shield)
+	}
+	if len(items) == 0 {
+		return "nothing"
+	}
+	return strings.Join(items, ", ")
+}
+
+// weaponDisplayName turns catalog names like "crossbow, light" into
+// "light crossbow", so they read well in a list.
+func weaponDisplayName(name string) string {
+	if base, kind, ok := strings.Cut(name, ", "); ok {
+		return kind + " " + base
+	}
+	return name
+}
+
+// ClassStartingEquipment lists the weapon and armor choices of each class's
+// starting equipment, following the Player's Handbook. Packs and tools are
+// left to the player.
+var ClassStartingEquipment = map[string][]StartingLoadout{
+	"barbarian": {
+		{MainHand: "greataxe"},
+		{MainHand: "handaxe", OffHand: "handaxe"},
+	},
+	"bard": {
+		{MainHand: "rapier", OffHand: "dagger", Armor: "leather"},
+		{MainHand: "longsword", OffHand: "dagger", Armor: "leather"},
+	},
+	"cleric": {
+		{MainHand: "mace", Armor: "scale mail", Shield: "shield"},
+		{MainHand: "mace", Armor: "leather", Shield: "shield"},
+		{MainHand: "warhammer", Armor: "scale mail", Shield: "shield"},
+		{MainHand: "crossbow, light", Armor: "scale mail"},
+	},
+	"druid": {
+		{MainHand: "scimitar", Armor: "leather", Shield: "shield"},
+		{MainHand: "quarterstaff", Armor: "leather"},
+	},
+	"fighter": {
+		{MainHand: "longsword", Armor: "chain mail", Shield: "shield"},
+		{MainHand: "greatsword", Armor: "chain mail"},
+		{MainHand: "longbow", Armor: "leather"},
+		{MainHand: "shortsword", OffHand: "shortsword", Armor: "leather"},
+	},
+	"monk": {
+		{MainHand: "shortsword"},
+		{MainHand: "quarterstaff"},
+	},
+	"paladin": {
+		{MainHand: "longsword", Armor: "chain mail", Shield: "shield"},
+		{MainHand: "warhammer", Armor: "chain mail", Shield: "shield"},
+		{MainHand: "greatsword", Armor: "chain mail"},
+	},
+	"ranger": {
+		{MainHand: "shortsword", OffHand: "shortsword", Armor: "scale mail"},
+		{MainHand: "longbow", Armor: "leather"},
+	},
+	"rogue": {
+		{MainHand: "rapier", OffHand: "dagger", Armor: "leather"},
+		{MainHand: "shortsword", OffHand: "shortsword", Armor: "leather"},
+		{MainHand: "shortbow", Armor: "leather"},
+	},
+	"sorcerer": {
+		{MainHand: "crossbow, light"},
+		{MainHand: "dagger", OffHand: "dagger"},
+	},
+	"warlock": {
+		{MainHand: "crossbow, light", Armor: "leather"},
+		{MainHand: "dagger", OffHand: "dagger", Armor: "leather"},
+	},
+	"wizard": {
+		{MainHand: "quarterstaff"},
+		{MainHand: "dagger"},
+	},
+}
+
+// StartingEquipmentOption is a loadout the character can use, resolved
+// against the equipment catalog.
+type StartingEquipmentOption struct {
+	Loadout   StartingLoadout
+	Equipment models.Equipment
+}
+
+// StartingEquipmentOptions returns the loadouts of the character's class it
+// is proficient with. A two-handed weapon leaves no hand for an off-hand
+// weapon or a shield, so such loadouts are left out as well.
+func StartingEquipmentOptions(c models.Character) ([]StartingEquipmentOption, error) {
+	loadouts, ok := ClassStartingEquipment[strings.ToLower(c.Class)]
+	if !ok {
+		return nil, fmt.Errorf("%w for class %s", ErrNoStartingEquipment, c.Class)
+	}
+
+	var options []StartingEquipmentOption
+	for _, loadout := range loadouts {
+		equipment, err := resolveLoadout(loadout)
+		if err != nil {
+			return nil, err
+		}
+		if canUseEquipment(c, equipment) {
+			options = append(options, StartingEquipmentOption{Loadout: loadout, Equipment: equipment})
+		}
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("%w: %s %s is not proficient with any of it", ErrNoStartingEquipment, c.Race, c.Class)
+	}
+	return options, nil
+}
+
+func resolveLoadout(loadout StartingLoadout) (models.Equipment, error) {
+	var equipment models.Equipment
+	for _, hand := range []struct {
+		name string
+		slot **models.Weapon
+	}{{loadout.MainHand, &equipment.MainHand}, {loadout.OffHand, &equipment.OffHand}} {
+		if hand.name == "" {
+			continue
+		}
+		weapon, ok := Weapons[hand.name]
+		if !ok {
+			return equipment, fmt.Errorf("weapon '%s' not found", hand.name)
+		}
+		*hand.slot = &weapon
+	}
+	if loadout.Armor != "" {
+		armor, ok := Armors[loadout.Armor]
+		if !ok {
+			return equipment, fmt.Errorf("armor '%s' not found", loadout.Armor)
+		}
+		armor.Name = armorDisplayName(loadout.Armor)
+		equipment.Armor = &armor
+	}
+	if loadout.Shield != "" {
+		shield, ok := Shields[loadout.Shield]
+		if !ok {
+			return equipment, fmt.Errorf("shield '%s' not found", loadout.Shield)
+		}
+		equipment.Shield = &shield
+	}
+	return equipment, nil
+}
+
+// fillEmptySlots equips the items of loadout in the slots of current that
+// are empty and returns the names of the items it added. Items that don't fit
+// next to what is already equipped, such as a shield next to a two-handed
+// weapon, are left out.
+func fillEmptySlots(current, loadout models.Equipment) (models.Equipment, []string) {
+	filled := current.Clone()
+	var added []string
+	if filled.MainHand == nil && loadout.MainHand != nil {
+		filled.MainHand = loadout.MainHand
+		added = append(added, weaponDisplayName(loadout.MainHand.Name))
+	}
+	twoHanded := filled.MainHand != nil && filled.MainHand.TwoHanded
+	if filled.Armor == nil && loadout.Armor != nil {
+		filled.Armor = loadout.Armor
+		added = append(added, loadout.Armor.Name)
+	}
+	if filled.OffHand == nil && filled.Shield == nil && loadout.OffHand != nil && !twoHanded {
+		filled.OffHand = loadout.OffHand
+		added = append(added, weaponDisplayName(loadout.OffHand.Name))
+	}
+	if filled.Shield == nil && filled.OffHand == nil && loadout.Shield != nil && !twoHanded {
+		filled.Shield = loadout.Shield
+		added = append(added, loadout.Shield.Name)
+	}
+	return filled, added
+}
+
+func canUseEquipment(c models.Character, equipment models.Equipment) bool {
+	for _, weapon := range []*models.Weapon{equipment.MainHand, equipment.OffHand} {
+		if weapon == nil {
+			continue
+		}
+		if !c.ProficientWithWeapon(*weapon) {
+			return false
+		}
+		if weapon.TwoHanded && (equipment.OffHand != nil || equipment.Shield != nil) {
+			return false
+		}
+	}
+	if equipment.Armor != nil && !c.ProficientWithArmor(*equipment.Armor) {
+		return false
+	}
+	if equipment.Shield != nil && !c.ProficientWithShield() {
+		return false
+	}
+	return true
+}
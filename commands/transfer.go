@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"dnd-character-sheet/storage"
+	"fmt"
+	"strings"
+)
+
+func ExportCharacter(characterName, filePath string) error {
+	character, err := storage.GetCharacterByName(characterName)
+	if err != nil {
+		return loadError(characterName, err)
+	}
+
+	if err := storage.WriteCharacterFile(filePath, character); err != nil {
+		return fmt.Errorf("failed to export character: %w", err)
+	}
+
+	fmt.Printf("Exported %s to %s\n", character.Name, filePath)
+	return nil
+}
+
+func ImportCharacter(filePath, rename string) error {
+	character, err := storage.ReadCharacterFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to import character: %w", err)
+	}
+
+	if rename = strings.TrimSpace(rename); rename != "" {
+		character.Name = rename
+	}
+
+	character.Race = strings.ToLower(strings.TrimSpace(character.Race))
+	character.Class = strings.ToLower(strings.TrimSpace(character.Class))
+	if err := character.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	existingCharacters, err := storage.LoadCharacters()
+	if err != nil {
+		return fmt.Errorf("failed to load characters: %w", err)
+	}
+	if _, exists := existingCharacters[character.Name]; exists {
+		return fmt.Errorf("character '%s' already exists, use -rename to import under another name", character.Name)
+	}
+
+	for _, existing := range existingCharacters {
+		if existing.ID == character.ID {
+			newID, err := storage.GetNextCharacterID()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("ID %d is already in use, assigned ID %d\n", character.ID, newID)
+			character.ID = newID
+			break
+		}
+	}
+
+	character.RecalculateDerivedStats()
+	SetupSpellcasting(&character)
+
+	if err := storage.SaveCharacter(character); err != nil {
+		return fmt.Errorf("failed to save imported character: %w", err)
+	}
+
+	fmt.Printf("Imported %s\n", character.Name)
+	return nil
+}
@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"dnd-character-sheet/models"
 	"dnd-character-sheet/storage"
 	"fmt"
+	"strings"
 )
 
 func UpdateCharacterLevel(characterName string, newLevel int) error {
@@ -13,14 +15,175 @@ func UpdateCharacterLevel(characterName string, newLevel int) error {
 
 	character, exists := characters[characterName]
 	if !exists {
-		return fmt.Errorf("character '%s' does not exist", characterName)
+		return loadError(characterName, storage.ErrCharacterNotFound)
 	}
+	before := character.Clone()
 
-	character.UpdateLevel(newLevel)
+	if err := setLevel(&character, newLevel); err != nil {
+		return err
+	}
+
+	if err := saveCharacterChange("update-level", before, character); err != nil {
+		return fmt.Errorf("cannot save character: %w", err)
+	}
+
+	return nil
+}
+
+// CharacterEdit lists the changes EditCharacter applies. Nil fields are left
+// unchanged.
+type CharacterEdit struct {
+	Level           *int
+	Expertise       []string
+	JackOfAllTrades *bool
+	Advantages      map[string]string
+	AbilityBonuses  map[string]int
+}
+
+func EditCharacter(characterName string, edit CharacterEdit) error {
+	characters, err := storage.LoadCharacters()
+	if err != nil {
+		return fmt.Errorf("cannot load characters: %w", err)
+	}
+
+	character, exists := characters[characterName]
+	if !exists {
+		return loadError(characterName, storage.ErrCharacterNotFound)
+	}
+	before := character.Clone()
+
+	if edit.Expertise != nil {
+		expertise, err := normalizeSkills(edit.Expertise)
+		if err != nil {
+			return err
+		}
+		character.SkillExpertise = expertise
+		if err := character.ValidateExpertise(); err != nil {
+			return invalid(err)
+		}
+	}
+	if edit.JackOfAllTrades != nil {
+		character.JackOfAllTrades = *edit.JackOfAllTrades
+	}
+	if edit.Advantages != nil {
+		advantages := map[string]string{}
+		for skill, note := range edit.Advantages {
+			name, ok := models.NormalizeSkillName(skill)
+			if !ok {
+				return invalidf("unknown skill '%s'", skill)
+			}
+			advantages[name] = note
+		}
+		character.SkillAdvantages = advantages
+	}
+
+	if edit.AbilityBonuses != nil {
+		bonuses := models.AbilityScores{}
+		for ability, bonus := range edit.AbilityBonuses {
+			name, ok := models.NormalizeAbilityName(ability)
+			if !ok {
+				return invalidf("unknown ability '%s'", ability)
+			}
+			bonuses.Set(name, bonus)
+		}
+		character.OtherBonuses = bonuses
+		character.RecalculateDerivedStats()
+	}
+
+	if edit.Level != nil {
+		if err := setLevel(&character, *edit.Level); err != nil {
+			return err
+		}
+	} else {
+		character.CalculateAllSkills()
+		character.CalculateCombatStats()
+	}
 
-	if err := storage.SaveCharacter(character); err != nil {
+	if err := saveCharacterChange("edit", before, character); err != nil {
 		return fmt.Errorf("cannot save character: %w", err)
 	}
 
 	return nil
 }
+
+// UpdateCharacter replaces the character saved as characterName with updated,
+// which may carry a new name. Stats that follow from the base scores, race,
+// class, level, proficiencies and equipment are recalculated, so callers only
+// need to send the values a player chooses.
+func UpdateCharacter(characterName string, updated models.Character) error {
+	existing, err := storage.GetCharacterByName(characterName)
+	if err != nil {
+		return err
+	}
+
+	updated.ID = existing.ID
+	if err := normalizeCharacter(&updated); err != nil {
+		return err
+	}
+	if err := checkSpellChanges(existing, updated); err != nil {
+		return err
+	}
+
+	if err := storage.ReplaceCharacter(characterName, updated); err != nil {
+		return err
+	}
+	if err := storage.RecordChange("update", existing, updated); err != nil {
+		return fmt.Errorf("saved character but failed to record history: %w", err)
+	}
+	return nil
+}
+
+// normalizeCharacter lowercases race and class, normalizes skill names,
+// recalculates everything derived from the player's choices, including the
+// spell slots, and validates the result.
+func normalizeCharacter(character *models.Character) error {
+	var err error
+	character.Race = strings.ToLower(strings.TrimSpace(character.Race))
+	character.Class = strings.ToLower(strings.TrimSpace(character.Class))
+	character.RacialBonuses = models.RacialAbilityBonuses(character.Race)
+
+	if character.SkillProficiencies, err = normalizeSkills(character.SkillProficiencies); err != nil {
+		return err
+	}
+	if character.SkillExpertise, err = normalizeSkills(character.SkillExpertise); err != nil {
+		return err
+	}
+	if len(character.SavingThrowProficiencies) == 0 {
+		character.SavingThrowProficiencies = models.GetSavingThrowProficiencies(character.Class)
+	}
+
+	character.RecalculateDerivedStats()
+	SetupSpellcasting(character)
+	return invalid(character.Validate())
+}
+
+// setLevel moves the character to level and recalculates what depends on it,
+// including the spell slots. A wizard going up writes the free spells of the
+// new levels into the spellbook.
+func setLevel(character *models.Character, level int) error {
+	if level < 1 || level > 20 {
+		return invalidf("level must be between 1 and 20, got %d", level)
+	}
+	gained := level > character.Level
+	character.UpdateLevel(level)
+	SetupSpellcasting(character)
+	if gained {
+		fillSpellbook(character)
+	}
+	return nil
+}
+
+func normalizeSkills(skills []string) ([]string, error) {
+	normalized := []string{}
+	for _, skill := range skills {
+		if strings.TrimSpace(skill) == "" {
+			continue
+		}
+		name, ok := models.NormalizeSkillName(skill)
+		if !ok {
+			return nil, invalidf("unknown skill '%s'", skill)
+		}
+		normalized = append(normalized, name)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password AddUser and SetPassword accept.
+const MinPasswordLength = 8
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+func AddUser(username, password, role string) error {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if role != models.RolePlayer && role != models.RoleDM {
+		return fmt.Errorf("role must be '%s' or '%s'", models.RolePlayer, models.RoleDM)
+	}
+
+	users, err := storage.LoadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	if _, exists := users[username]; exists {
+		return fmt.Errorf("%w: %s", storage.ErrUserExists, username)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	users[username] = models.User{Username: username, PasswordHash: hash, Role: role}
+	if err := storage.SaveUsers(users); err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	fmt.Printf("Added %s %s\n", role, username)
+	return nil
+}
+
+func SetPassword(username, password string) error {
+	users, err := storage.LoadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	user, exists := users[strings.ToLower(username)]
+	if !exists {
+		return fmt.Errorf("%w: %s", storage.ErrUserNotFound, username)
+	}
+
+	if user.PasswordHash, err = hashPassword(password); err != nil {
+		return err
+	}
+	users[user.Username] = user
+	return storage.SaveUsers(users)
+}
+
+func RemoveUser(username string) error {
+	users, err := storage.LoadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	key := strings.ToLower(username)
+	if _, exists := users[key]; !exists {
+		return fmt.Errorf("%w: %s", storage.ErrUserNotFound, username)
+	}
+	delete(users, key)
+	return storage.SaveUsers(users)
+}
+
+func ListUsers() error {
+	users, err := storage.LoadUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	if len(users) == 0 {
+		fmt.Println("No users yet")
+		return nil
+	}
+
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("- %s (%s)\n", name, users[name].Role)
+	}
+	return nil
+}
+
+// dummyHash is compared against for unknown users, so they take as long to
+// reject as a wrong password and don't reveal which usernames exist.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// AuthenticateUser returns the account when password matches. Unknown users
+// and wrong passwords give the same error and take as long.
+func AuthenticateUser(username, password string) (models.User, error) {
+	user, err := storage.GetUser(username)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"dnd-character-sheet/models"
+)
+
+func TestAuthenticateUser(t *testing.T) {
+	useTempStorage(t)
+	if err := AddUser("Alice", "correct horse", models.RolePlayer); err != nil {
+		t.Fatal(err)
+	}
+
+	if user, err := AuthenticateUser("alice", "correct horse"); err != nil || user.Username != "alice" {
+		t.Errorf("right password: %+v, %v", user, err)
+	}
+	if _, err := AuthenticateUser("alice", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("wrong password: %v", err)
+	}
+	if _, err := AuthenticateUser("bob", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("unknown user: %v", err)
+	}
+}
+
+func TestAuthenticateUnknownUserTakesAsLong(t *testing.T) {
+	useTempStorage(t)
+	if err := AddUser("alice", "correct horse", models.RolePlayer); err != nil {
+		t.Fatal(err)
+	}
+	timed := func(username string) time.Duration {
+		start := time.Now()
+		_, _ = AuthenticateUser(username, "wrong horse")
+		return time.Since(start)
+	}
+	timed("bob")
+
+	known, unknown := timed("alice"), timed("bob")
+	if unknown < known/4 {
+		t.Errorf("rejecting an unknown user took %s, a wrong password %s", unknown, known)
+	}
+}
@@ -1,8 +1,11 @@
 package commands
 
 import (
+	"dnd-character-sheet/models"
 	"dnd-character-sheet/storage"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 )
@@ -15,7 +18,12 @@ var pactCasters = map[string]bool{
 	"warlock": true,
 }
 
-func ViewCharacter(name string) error {
+func ViewCharacter(name, format string) error {
+	renderer, ok := SheetRenderers[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unknown format %q (available: %s)", format, strings.Join(RendererFormats(), ", "))
+	}
+
 	characters, err := storage.LoadCharacters()
 	if err != nil {
 		return err
@@ -27,65 +35,189 @@ func ViewCharacter(name string) error {
 		}
 
 		c.CalculateCombatStats()
+		return renderer.Render(os.Stdout, c)
+	}
 
-		fmt.Printf("Name: %s\n", c.Name)
-		fmt.Printf("Class: %s\n", strings.ToLower(c.Class))
-		fmt.Printf("Race: %s\n", strings.ToLower(c.Race))
-		fmt.Printf("Background: %s\n", strings.ToLower(c.Background))
-		fmt.Printf("Level: %d\n", c.Level)
-
-		fmt.Println("Ability scores:")
-		fmt.Printf("  STR: %d (%+d)\n", c.Abilities.Strength, c.Abilities.Modifier("Strength"))
-		fmt.Printf("  DEX: %d (%+d)\n", c.Abilities.Dexterity, c.Abilities.Modifier("Dexterity"))
-		fmt.Printf("  CON: %d (%+d)\n", c.Abilities.Constitution, c.Abilities.Modifier("Constitution"))
-		fmt.Printf("  INT: %d (%+d)\n", c.Abilities.Intelligence, c.Abilities.Modifier("Intelligence"))
-		fmt.Printf("  WIS: %d (%+d)\n", c.Abilities.Wisdom, c.Abilities.Modifier("Wisdom"))
-		fmt.Printf("  CHA: %d (%+d)\n", c.Abilities.Charisma, c.Abilities.Modifier("Charisma"))
-
-		fmt.Printf("Proficiency bonus: %+d\n", c.ProficiencyBonus)
-		fmt.Printf("Skill proficiencies: %s\n", formatSkillProficiencies(c.SkillProficiencies))
-
-		if c.Equipment.MainHand != nil {
-			fmt.Printf("Main hand: %s\n", c.Equipment.MainHand.Name)
-		}
-		if c.Equipment.OffHand != nil {
-			fmt.Printf("Off hand: %s\n", c.Equipment.OffHand.Name)
+	return fmt.Errorf(`character "%s" not found`, name)
+}
+
+// TextRenderer renders the plain-text sheet printed by "view".
+type TextRenderer struct{}
+
+func (TextRenderer) Render(w io.Writer, c models.Character) error {
+	fmt.Fprintf(w, "Name: %s\n", c.Name)
+	fmt.Fprintf(w, "Class: %s\n", strings.ToLower(c.Class))
+	fmt.Fprintf(w, "Race: %s\n", strings.ToLower(c.Race))
+	fmt.Fprintf(w, "Background: %s\n", strings.ToLower(c.Background))
+	fmt.Fprintf(w, "Level: %d\n", c.Level)
+
+	fmt.Fprintln(w, "Ability scores:")
+	for _, ability := range abilityOrder {
+		fmt.Fprintf(w, "  %s: %d (%+d)%s\n", strings.ToUpper(ability[:3]), c.Abilities.Score(ability),
+			c.Abilities.Modifier(ability), formatScoreBreakdown(c, ability))
+	}
+
+	fmt.Fprintln(w, "Saving throws:")
+	for _, ability := range abilityOrder {
+		marker := ""
+		if c.HasSavingThrowProficiency(ability) {
+			marker = " (proficient)"
 		}
-		if c.Equipment.Armor != nil {
-			fmt.Printf("Armor: %s\n", c.Equipment.Armor.Name)
+		fmt.Fprintf(w, "  %s: %+d%s\n", strings.ToUpper(ability[:3]), c.SavingThrows[ability], marker)
+	}
+
+	fmt.Fprintf(w, "Proficiency bonus: %+d\n", c.ProficiencyBonus)
+	fmt.Fprintf(w, "Skill proficiencies: %s\n", formatSkillProficiencies(c.SkillProficiencies))
+	if len(c.SkillExpertise) > 0 {
+		fmt.Fprintf(w, "Expertise: %s\n", formatSkillProficiencies(append([]string(nil), c.SkillExpertise...)))
+	}
+	if c.HasJackOfAllTrades() {
+		fmt.Fprintf(w, "Jack of All Trades: %+d to non-proficient checks\n", models.HalfProficient.Bonus(c.ProficiencyBonus))
+	}
+	for _, skill := range sortedKeys(c.SkillAdvantages) {
+		fmt.Fprintf(w, "Advantage on %s: %s\n", strings.ToLower(skill), c.SkillAdvantages[skill])
+	}
+
+	if c.Equipment.MainHand != nil {
+		fmt.Fprintf(w, "Main hand: %s\n", c.Equipment.MainHand.Name)
+	}
+	if c.Equipment.OffHand != nil {
+		fmt.Fprintf(w, "Off hand: %s\n", c.Equipment.OffHand.Name)
+	}
+	if c.Equipment.Armor != nil {
+		fmt.Fprintf(w, "Armor: %s\n", c.Equipment.Armor.Name)
+	}
+	if c.Equipment.Shield != nil {
+		fmt.Fprintf(w, "Shield: %s\n", c.Equipment.Shield.Name)
+	}
+
+	if len(c.SpellSlots) > 0 {
+		fmt.Fprintln(w, "Spell slots:")
+		for _, lvl := range sortedSlotLevels(c.SpellSlots) {
+			fmt.Fprintf(w, "  Level %d: %d/%d\n", lvl, c.RemainingSpellSlots(lvl), c.SpellSlots[lvl])
 		}
-		if c.Equipment.Shield != nil {
-			fmt.Printf("Shield: %s\n", c.Equipment.Shield.Name)
+	}
+
+	if fullCasters[strings.ToLower(c.Class)] || pactCasters[strings.ToLower(c.Class)] {
+		if c.SpellcastingAbility != "" {
+			fmt.Fprintf(w, "Spellcasting ability: %s\n", strings.ToLower(c.SpellcastingAbility))
+			fmt.Fprintf(w, "Spell save DC: %d\n", c.SpellSaveDC)
+			fmt.Fprintf(w, "Spell attack bonus: %+d\n", c.SpellAttackBonus)
 		}
+	}
 
-		if len(c.SpellSlots) > 0 {
-			fmt.Println("Spell slots:")
-			levels := make([]int, 0, len(c.SpellSlots))
-			for lvl := range c.SpellSlots {
-				levels = append(levels, lvl)
+	if len(c.Spells) > 0 {
+		fmt.Fprintln(w, "Spells:")
+		for _, spell := range sortedSpells(c.Spells) {
+			fmt.Fprintf(w, "  %s (%s)\n", spell.Name, strings.Join(spellTags(spell), ", "))
+			if !spell.HasDetails() {
+				continue
+			}
+			fmt.Fprintf(w, "    %s\n", strings.Join(spellCardFacts(spell), "; "))
+			for _, paragraph := range strings.Split(spell.Description, "\n\n") {
+				fmt.Fprintf(w, "    %s\n", paragraph)
 			}
-			sort.Ints(levels)
-			for _, lvl := range levels {
-				fmt.Printf("  Level %d: %d\n", lvl, c.SpellSlots[lvl])
+			if spell.HigherLevel != "" {
+				fmt.Fprintf(w, "    At higher levels: %s\n", spell.HigherLevel)
 			}
 		}
+	}
 
-		if fullCasters[strings.ToLower(c.Class)] || pactCasters[strings.ToLower(c.Class)] {
-			if c.SpellcastingAbility != "" {
-				fmt.Printf("Spellcasting ability: %s\n", strings.ToLower(c.SpellcastingAbility))
-				fmt.Printf("Spell save DC: %d\n", c.SpellSaveDC)
-				fmt.Printf("Spell attack bonus: %+d\n", c.SpellAttackBonus)
-			}
+	fmt.Fprintf(w, "Armor class: %d\n", c.ArmorClass)
+	fmt.Fprintf(w, "Initiative bonus: %d\n", c.Initiative)
+	fmt.Fprintf(w, "Passive perception: %d\n", c.PassivePerception)
+	fmt.Fprintf(w, "Passive investigation: %d\n", c.PassiveInvestigation)
+	fmt.Fprintf(w, "Passive insight: %d\n", c.PassiveInsight)
+
+	return nil
+}
+
+// formatScoreBreakdown shows where a total score comes from when it differs
+// from the base score, e.g. " [base 15, racial +2]".
+func formatScoreBreakdown(c models.Character, ability string) string {
+	base := c.BaseAbilities.Score(ability)
+	if base == 0 || base == c.Abilities.Score(ability) {
+		return ""
+	}
+	parts := []string{fmt.Sprintf("base %d", base)}
+	if racial := c.RacialBonuses.Score(ability); racial != 0 {
+		parts = append(parts, fmt.Sprintf("racial %+d", racial))
+	}
+	if other := c.OtherBonuses.Score(ability); other != 0 {
+		parts = append(parts, fmt.Sprintf("other %+d", other))
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+func sortedSlotLevels(slots map[int]int) []int {
+	levels := make([]int, 0, len(slots))
+	for lvl := range slots {
+		levels = append(levels, lvl)
+	}
+	sort.Ints(levels)
+	return levels
+}
+
+// sortedSpells orders spells by level, then name.
+func sortedSpells(spells []models.Spell) []models.Spell {
+	sorted := append([]models.Spell(nil), spells...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Level != sorted[j].Level {
+			return sorted[i].Level < sorted[j].Level
 		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
 
-		fmt.Printf("Armor class: %d\n", c.ArmorClass)
-		fmt.Printf("Initiative bonus: %d\n", c.Initiative)
-		fmt.Printf("Passive perception: %d\n", c.PassivePerception)
+// spellTags describes a spell in a few words, e.g. "level 1 evocation,
+// prepared".
+func spellTags(spell models.Spell) []string {
+	kind := "cantrip"
+	if spell.Level > 0 {
+		kind = fmt.Sprintf("level %d", spell.Level)
+	}
+	if spell.School != "" {
+		kind += " " + strings.ToLower(spell.School)
+	}
+	tags := []string{kind}
+	if spell.Ritual {
+		tags = append(tags, "ritual")
+	}
+	if spell.Concentration {
+		tags = append(tags, "concentration")
+	}
+	if spell.Prepared {
+		tags = append(tags, "prepared")
+	}
+	return tags
+}
 
-		return nil
+// spellCardFacts lists the header lines of a spell card.
+func spellCardFacts(spell models.Spell) []string {
+	var facts []string
+	if spell.CastingTime != "" {
+		facts = append(facts, "Casting time: "+spell.CastingTime)
 	}
+	if spell.Range != "" {
+		facts = append(facts, "Range: "+spell.Range)
+	}
+	if len(spell.Components) > 0 {
+		facts = append(facts, "Components: "+spell.ComponentsText())
+	}
+	if spell.Duration != "" {
+		facts = append(facts, "Duration: "+spell.Duration)
+	}
+	return facts
+}
 
-	return fmt.Errorf(`character "%s" not found`, name)
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func formatSkillProficiencies(skills []string) string {
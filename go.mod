@@ -1,3 +1,11 @@
 module dnd-character-sheet
 
 go 1.25.0
+
+require (
+	github.com/go-pdf/fpdf v0.9.0
+	golang.org/x/crypto v0.54.0
+	golang.org/x/term v0.45.0
+)
+
+require golang.org/x/sys v0.47.0 // indirect
@@ -1,38 +1,184 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"dnd-character-sheet/api"
 	"dnd-character-sheet/commands"
 	"dnd-character-sheet/models"
+	"dnd-character-sheet/server"
+	"dnd-character-sheet/storage"
+	"errors"
 	"flag"
 	"fmt"
+	"math/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/term"
 )
 
 func printUsage() {
 	fmt.Printf(`Usage:
-		 %s create -name CHARACTER_NAME -race RACE -class CLASS -level N -str N -dex N -con N -int N -wis N -cha N
-		 %s view -name CHARACTER_NAME
+		 %s create -name CHARACTER_NAME -race RACE -class CLASS -level N -str N -dex N -con N -int N -wis N -cha N [-skills A,B] [-expertise A,B]
+		 %s edit -name CHARACTER_NAME [-level N] [-expertise A,B] [-jack-of-all-trades] [-advantage SKILL:NOTE,...] [-bonus ABILITY:N,...]
+		 %s view -name CHARACTER_NAME [-format text|markdown]
 		 %s list
 		 %s delete -name CHARACTER_NAME
 		 %s equip -name CHARACTER_NAME -weapon WEAPON_NAME -slot SLOT
 		 %s equip -name CHARACTER_NAME -armor ARMOR_NAME
 		 %s equip -name CHARACTER_NAME -shield SHIELD_NAME
 		 %s learn-spell -name CHARACTER_NAME -spell SPELL_NAME
-		 %s prepare-spell -name CHARACTER_NAME -spell SPELL_NAME
-		 %s enrich -name CHARACTER_NAME
-`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0],  os.Args[0])
+		 %s prepare-spell -name CHARACTER_NAME (-spell SPELL_NAME | -spells A,B) [-replace]
+		 %s unprepare-spell -name CHARACTER_NAME (-spell SPELL_NAME | -spells A,B)
+		 %s cast-spell -name CHARACTER_NAME -spell SPELL_NAME [-level N | -ritual]
+		 %s copy-spell -name CHARACTER_NAME -spell SPELL_NAME
+		 %s enrich -name CHARACTER_NAME [-option N | -choose] [-seed N] [-pin A,B] [-exclude A,B]
+		 %s sync-catalog [-workers N] [-interval 200ms]
+		 %s migrate [-dry-run]
+		 %s history -name CHARACTER_NAME
+		 %s undo -name CHARACTER_NAME [-steps N]
+		 %s export -name CHARACTER_NAME -o FILE
+		 %s import -file FILE [-rename NEW_NAME]
+		 %s export-pdf -name CHARACTER_NAME -o FILE
+		 %s damage -name CHARACTER_NAME -amount N
+		 %s heal -name CHARACTER_NAME -amount N
+		 %s rest -name CHARACTER_NAME -type short|long [-hit-dice N]
+		 %s serve [-addr :8080] [-data FILE] [-templates DIR] [-static DIR]
+		 %s user-add -username NAME [-role player|dm]
+		 %s user-passwd -username NAME
+		 %s user-remove -username NAME
+		 %s user-list
+
+Set DND_DATA_FILE to use another characters file for every command.
+Set DND_CATALOG_CACHE and DND_CATALOG_TTL (e.g. 720h) to move the offline SRD
+catalog used by enrich, or to change how long it is used before refreshing.
+Set DND_API_URL to sync the catalog from a self-hosted 5e-database mirror.
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+}
+
+// envOr returns the environment variable key, or fallback when it is unset.
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// readPassword prompts for a password twice. Input is hidden when stdin is
+// a terminal; otherwise a single line is read so scripts can pipe it in.
+func readPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Print("Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	fmt.Print("Repeat password: ")
+	repeated, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if string(password) != string(repeated) {
+		return "", errors.New("passwords do not match")
+	}
+	return string(password), nil
+}
+
+// chooseStartingEquipment lists the starting equipment the character can
+// take and asks for one until a valid number is entered.
+func chooseStartingEquipment(name string) (int, error) {
+	character, err := storage.GetCharacterByName(name)
+	if err != nil {
+		return 0, err
+	}
+	options, err := commands.StartingEquipmentOptions(character)
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("Starting equipment for %s:\n", character.Name)
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option.Loadout)
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("Choose 1-%d: ", len(options))
+		line, err := reader.ReadString('\n')
+		choice, convErr := strconv.Atoi(strings.TrimSpace(line))
+		if convErr == nil && choice >= 1 && choice <= len(options) {
+			return choice, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// printProgress keeps a single status line on stderr up to date while the
+// SRD catalog downloads.
+func printProgress(resource string, done, total int) {
+	fmt.Fprintf(os.Stderr, "\rFetching %s: %d/%d", resource, done, total)
+	if done == total {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// spellNames combines a single -spell flag with a -spells list.
+func spellNames(spell, spells string) []string {
+	names := splitList(spells)
+	if spell = strings.TrimSpace(spell); spell != "" {
+		names = append([]string{spell}, names...)
+	}
+	return names
 }
 
 func main() {
-	if err := commands.LoadSpellsFromCSV("data/spells.csv"); err != nil {
-		fmt.Println("failed to load spells:", err)
+	if err := commands.LoadCatalog(); err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	if err := commands.LoadEquipmentCSV("data/equipment.csv"); err != nil {
-		fmt.Println("failed to load equipment:", err)
-		os.Exit(1)
+	if dataFile := os.Getenv("DND_DATA_FILE"); dataFile != "" {
+		storage.Configure(dataFile)
+	}
+	if baseURL := os.Getenv("DND_API_URL"); baseURL != "" {
+		api.DefaultClient = api.NewClient(baseURL)
+	}
+	api.DefaultClient.Progress = printProgress
+	api.CacheFilePath = envOr("DND_CATALOG_CACHE", api.CacheFilePath)
+	if ttl := os.Getenv("DND_CATALOG_TTL"); ttl != "" {
+		duration, err := time.ParseDuration(ttl)
+		if err != nil {
+			fmt.Println("invalid DND_CATALOG_TTL:", err)
+			os.Exit(2)
+		}
+		api.CacheTTL = duration
 	}
 
 	if len(os.Args) < 2 {
@@ -60,6 +206,7 @@ func main() {
 		wisdom := createCmd.Int("wis", 10, "Wisdom")
 		charisma := createCmd.Int("cha", 10, "Charisma")
 		skillsFlag := createCmd.String("skills", "", "Comma-separated skill list")
+		expertiseFlag := createCmd.String("expertise", "", "Comma-separated skills with expertise")
 		_ = createCmd.Parse(os.Args[2:])
 
 		if *characterName == "" {
@@ -70,10 +217,7 @@ func main() {
 
 		var skillProficiencies []string
 		if *skillsFlag != "" {
-			skillProficiencies = strings.Split(*skillsFlag, ",")
-			for i := range skillProficiencies {
-				skillProficiencies[i] = strings.TrimSpace(skillProficiencies[i])
-			}
+			skillProficiencies = splitList(*skillsFlag)
 		} else {
 			classKey := strings.ToLower(*characterClass)
 			if skills, ok := models.ClassSkills[classKey]; ok {
@@ -83,22 +227,80 @@ func main() {
 
 		abilityScores := []int{*strength, *dexterity, *constitution, *intelligence, *wisdom, *charisma}
 
-		if err := commands.CreateCharacter(*characterName, *playerName, *characterRace, *characterClass, *background, *level, abilityScores, skillProficiencies); err != nil {
-			fmt.Printf(`failed to save character "%s"`+"\n", *characterName)
+		if err := commands.CreateCharacter(*characterName, *playerName, *characterRace, *characterClass, *background, *level, abilityScores, skillProficiencies, splitList(*expertiseFlag)); err != nil {
+			fmt.Printf(`failed to save character "%s": %v`+"\n", *characterName, err)
 			os.Exit(1)
 		}
 		fmt.Printf("saved character %s\n", *characterName)
 
+	// ---------------- EDIT CHARACTER ----------------
+	case "edit":
+		editCmd := flag.NewFlagSet("edit", flag.ExitOnError)
+		characterName := editCmd.String("name", "", "Character Name (required)")
+		level := editCmd.Int("level", 0, "Level")
+		expertise := editCmd.String("expertise", "", "Comma-separated skills with expertise")
+		jackOfAllTrades := editCmd.Bool("jack-of-all-trades", false, "Add half proficiency to non-proficient checks")
+		advantages := editCmd.String("advantage", "", "Comma-separated SKILL:NOTE pairs for features granting advantage")
+		bonuses := editCmd.String("bonus", "", "Comma-separated ABILITY:N ability score bonuses besides race (e.g. str:2)")
+		_ = editCmd.Parse(os.Args[2:])
+
+		if *characterName == "" {
+			fmt.Println("character name is required")
+			editCmd.Usage()
+			os.Exit(2)
+		}
+
+		var edit commands.CharacterEdit
+		var parseErr error
+		editCmd.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "level":
+				edit.Level = level
+			case "expertise":
+				edit.Expertise = append([]string{}, splitList(*expertise)...)
+			case "jack-of-all-trades":
+				edit.JackOfAllTrades = jackOfAllTrades
+			case "advantage":
+				edit.Advantages = map[string]string{}
+				for _, entry := range splitList(*advantages) {
+					skill, note, _ := strings.Cut(entry, ":")
+					edit.Advantages[strings.TrimSpace(skill)] = strings.TrimSpace(note)
+				}
+			case "bonus":
+				edit.AbilityBonuses = map[string]int{}
+				for _, entry := range splitList(*bonuses) {
+					ability, value, _ := strings.Cut(entry, ":")
+					bonus, err := strconv.Atoi(strings.TrimSpace(value))
+					if err != nil {
+						parseErr = fmt.Errorf("invalid bonus %q, expected ABILITY:N", entry)
+						return
+					}
+					edit.AbilityBonuses[strings.TrimSpace(ability)] = bonus
+				}
+			}
+		})
+		if parseErr != nil {
+			fmt.Println(parseErr)
+			os.Exit(2)
+		}
+
+		if err := commands.EditCharacter(*characterName, edit); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("updated character %s\n", *characterName)
+
 	// ---------------- VIEW CHARACTER ----------------
 	case "view":
 		viewCmd := flag.NewFlagSet("view", flag.ExitOnError)
 		characterName := viewCmd.String("name", "", "Character Name (required)")
+		format := viewCmd.String("format", "text", "Output format (text, markdown)")
 		_ = viewCmd.Parse(os.Args[2:])
 		if *characterName == "" {
 			fmt.Println("character name is required")
 			os.Exit(2)
 		}
-		if err := commands.ViewCharacter(*characterName); err != nil {
+		if err := commands.ViewCharacter(*characterName, *format); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -167,10 +369,12 @@ func main() {
 				fmt.Printf("Armor '%s' not found in CSV\n", *armorName)
 				os.Exit(1)
 			}
-			if err := commands.AddArmor(*characterName, armor.Name); err != nil {
+			name, err := commands.AddArmor(*characterName, armor.Name)
+			if err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
+			fmt.Printf("Equipped armor %s\n", name)
 			return
 		}
 
@@ -201,31 +405,97 @@ func main() {
 			fmt.Println("character name and spell name are required")
 			os.Exit(2)
 		}
-		if err := commands.LearnSpell(*characterName, *spellName); err != nil {
+		summary, err := commands.LearnSpell(*characterName, *spellName)
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		fmt.Println(summary)
 
 	// ---------------- PREPARE SPELL ----------------
 	case "prepare-spell":
 		prepareCmd := flag.NewFlagSet("prepare-spell", flag.ExitOnError)
 		characterName := prepareCmd.String("name", "", "Character Name")
 		spellName := prepareCmd.String("spell", "", "Spell Name")
-		level := prepareCmd.Int("level", 1, "Spell Level")
+		spellsFlag := prepareCmd.String("spells", "", "Comma-separated spell names")
+		replace := prepareCmd.Bool("replace", false, "Unprepare every other spell")
 		_ = prepareCmd.Parse(os.Args[2:])
+		spells := spellNames(*spellName, *spellsFlag)
+		if *characterName == "" || (len(spells) == 0 && !*replace) {
+			fmt.Println("character name and spell names are required")
+			os.Exit(2)
+		}
+		summary, err := commands.PrepareSpell(*characterName, spells, *replace)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(summary)
+
+	// ---------------- UNPREPARE SPELL ----------------
+	case "unprepare-spell":
+		unprepareCmd := flag.NewFlagSet("unprepare-spell", flag.ExitOnError)
+		characterName := unprepareCmd.String("name", "", "Character Name")
+		spellName := unprepareCmd.String("spell", "", "Spell Name")
+		spellsFlag := unprepareCmd.String("spells", "", "Comma-separated spell names")
+		_ = unprepareCmd.Parse(os.Args[2:])
+		spells := spellNames(*spellName, *spellsFlag)
+		if *characterName == "" || len(spells) == 0 {
+			fmt.Println("character name and spell names are required")
+			os.Exit(2)
+		}
+		summary, err := commands.UnprepareSpell(*characterName, spells)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(summary)
+
+	// ---------------- CAST SPELL ----------------
+	case "cast-spell":
+		castCmd := flag.NewFlagSet("cast-spell", flag.ExitOnError)
+		characterName := castCmd.String("name", "", "Character Name")
+		spellName := castCmd.String("spell", "", "Spell Name")
+		level := castCmd.Int("level", 0, "Slot level to cast with (default the lowest left from the spell's level)")
+		ritual := castCmd.Bool("ritual", false, "Cast the spell as a ritual, without a slot")
+		_ = castCmd.Parse(os.Args[2:])
+		if *characterName == "" || *spellName == "" {
+			fmt.Println("character name and spell name are required")
+			os.Exit(2)
+		}
+		summary, err := commands.CastSpell(*characterName, *spellName, *level, *ritual)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(summary)
+
+	// ---------------- COPY SPELL ----------------
+	case "copy-spell":
+		copyCmd := flag.NewFlagSet("copy-spell", flag.ExitOnError)
+		characterName := copyCmd.String("name", "", "Character Name")
+		spellName := copyCmd.String("spell", "", "Spell Name")
+		_ = copyCmd.Parse(os.Args[2:])
 		if *characterName == "" || *spellName == "" {
 			fmt.Println("character name and spell name are required")
 			os.Exit(2)
 		}
-		if err := commands.PrepareSpell(*characterName, *spellName, *level); err != nil {
+		summary, err := commands.CopySpell(*characterName, *spellName)
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		fmt.Println(summary)
 
 	// ---------------- ENRICH CHARACTER ----------------
 	case "enrich":
 		enrichCmd := flag.NewFlagSet("enrich", flag.ExitOnError)
 		characterName := enrichCmd.String("name", "", "Character Name (required)")
+		option := enrichCmd.Int("option", 0, "Starting equipment option to take (default the first)")
+		choose := enrichCmd.Bool("choose", false, "Choose the starting equipment interactively")
+		seed := enrichCmd.Int64("seed", 0, "Seed for the spell pick, to repeat an earlier result (default random)")
+		pin := enrichCmd.String("pin", "", "Spells to always pick, comma separated")
+		exclude := enrichCmd.String("exclude", "", "Spells never to pick, comma separated")
 		_ = enrichCmd.Parse(os.Args[2:])
 
 		if *characterName == "" {
@@ -233,14 +503,251 @@ func main() {
 			enrichCmd.Usage()
 			os.Exit(2)
 		}
+		if *choose {
+			if *option != 0 {
+				fmt.Println("use either -choose or -option")
+				os.Exit(2)
+			}
+			chosen, err := chooseStartingEquipment(*characterName)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			*option = chosen
+		}
 
-		if err := commands.EnrichCharacter(*characterName); err != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		seeded := false
+		enrichCmd.Visit(func(f *flag.Flag) { seeded = seeded || f.Name == "seed" })
+		if !seeded {
+			*seed = time.Now().UnixNano()
+			fmt.Printf("Picking spells with seed %d\n", *seed)
+		}
+		opts := commands.EnrichOptions{
+			EquipmentOption: *option,
+			Rand:            rand.New(rand.NewSource(*seed)),
+			PinSpells:       splitList(*pin),
+			ExcludeSpells:   splitList(*exclude),
+		}
+		if err := commands.EnrichCharacter(ctx, *characterName, opts); err != nil {
 			fmt.Println("failed to enrich character:", err)
 			os.Exit(1)
 		}
 
 		fmt.Printf("Enriched character %s with API data\n", *characterName)
 
+	// ---------------- SYNC CATALOG ----------------
+	case "sync-catalog":
+		syncCmd := flag.NewFlagSet("sync-catalog", flag.ExitOnError)
+		workers := syncCmd.Int("workers", api.DefaultClient.Workers, "Requests to run at once")
+		interval := syncCmd.Duration("interval", api.DefaultClient.RequestInterval, "Minimum time between requests")
+		_ = syncCmd.Parse(os.Args[2:])
+		api.DefaultClient.Workers = *workers
+		api.DefaultClient.RequestInterval = *interval
+
+		fmt.Println("Downloading the SRD catalog...")
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		catalog, err := api.SyncCatalog(ctx)
+		if err != nil {
+			fmt.Println("failed to sync catalog:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %d spells and the spell lists of %d classes to %s\n", len(catalog.Spells), len(catalog.Classes), api.CacheFilePath)
+
+	// ---------------- MIGRATE ----------------
+	case "migrate":
+		migrateCmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+		dryRun := migrateCmd.Bool("dry-run", false, "Report what would change without saving")
+		_ = migrateCmd.Parse(os.Args[2:])
+
+		if err := commands.MigrateCharacters(*dryRun); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- HISTORY ----------------
+	case "history":
+		historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+		characterName := historyCmd.String("name", "", "Character Name (required)")
+		_ = historyCmd.Parse(os.Args[2:])
+		if *characterName == "" {
+			fmt.Println("character name is required")
+			os.Exit(2)
+		}
+		if err := commands.ShowHistory(*characterName); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- UNDO ----------------
+	case "undo":
+		undoCmd := flag.NewFlagSet("undo", flag.ExitOnError)
+		characterName := undoCmd.String("name", "", "Character Name (required)")
+		steps := undoCmd.Int("steps", 1, "Number of changes to undo")
+		_ = undoCmd.Parse(os.Args[2:])
+		if *characterName == "" {
+			fmt.Println("character name is required")
+			os.Exit(2)
+		}
+		if err := commands.UndoCharacter(*characterName, *steps); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- EXPORT ----------------
+	case "export":
+		exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+		characterName := exportCmd.String("name", "", "Character Name (required)")
+		output := exportCmd.String("o", "", "Output file (required)")
+		_ = exportCmd.Parse(os.Args[2:])
+		if *characterName == "" || *output == "" {
+			fmt.Println("character name and output file are required")
+			os.Exit(2)
+		}
+		if err := commands.ExportCharacter(*characterName, *output); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- IMPORT ----------------
+	case "import":
+		importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+		file := importCmd.String("file", "", "Character file (required)")
+		rename := importCmd.String("rename", "", "Import under a different name")
+		_ = importCmd.Parse(os.Args[2:])
+		if *file == "" {
+			fmt.Println("file is required")
+			os.Exit(2)
+		}
+		if err := commands.ImportCharacter(*file, *rename); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- EXPORT PDF ----------------
+	case "export-pdf":
+		pdfCmd := flag.NewFlagSet("export-pdf", flag.ExitOnError)
+		characterName := pdfCmd.String("name", "", "Character Name (required)")
+		output := pdfCmd.String("o", "", "Output PDF file (required)")
+		_ = pdfCmd.Parse(os.Args[2:])
+		if *characterName == "" || *output == "" {
+			fmt.Println("character name and output file are required")
+			os.Exit(2)
+		}
+		if err := commands.ExportCharacterPDF(*characterName, *output); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- HIT POINTS ----------------
+	case "damage", "heal":
+		hpCmd := flag.NewFlagSet(command, flag.ExitOnError)
+		characterName := hpCmd.String("name", "", "Character Name (required)")
+		amount := hpCmd.Int("amount", 0, "Hit points")
+		_ = hpCmd.Parse(os.Args[2:])
+		if *characterName == "" {
+			fmt.Println("character name is required")
+			os.Exit(2)
+		}
+		var summary string
+		var err error
+		if command == "damage" {
+			summary, err = commands.DamageCharacter(*characterName, *amount)
+		} else {
+			summary, err = commands.HealCharacter(*characterName, *amount)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(summary)
+
+	// ---------------- REST ----------------
+	case "rest":
+		restCmd := flag.NewFlagSet("rest", flag.ExitOnError)
+		characterName := restCmd.String("name", "", "Character Name (required)")
+		kind := restCmd.String("type", "", "Rest type: short or long")
+		hitDice := restCmd.Int("hit-dice", 0, "Hit dice to spend on a short rest")
+		_ = restCmd.Parse(os.Args[2:])
+		if *characterName == "" || *kind == "" {
+			fmt.Println("character name and rest type are required")
+			os.Exit(2)
+		}
+		summary, err := commands.RestCharacter(*characterName, *kind, *hitDice)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(summary)
+
+	// ---------------- SERVE ----------------
+	case "serve":
+		serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+		addr := serveCmd.String("addr", envOr("DND_ADDR", ":8080"), "Listen address (DND_ADDR)")
+		dataFile := serveCmd.String("data", storage.CharactersFilePath, "Characters file, history is kept next to it (DND_DATA_FILE)")
+		templateDir := serveCmd.String("templates", os.Getenv("DND_TEMPLATE_DIR"), "Template directory instead of the embedded templates (DND_TEMPLATE_DIR)")
+		staticDir := serveCmd.String("static", os.Getenv("DND_STATIC_DIR"), "Static file directory instead of the embedded files (DND_STATIC_DIR)")
+		_ = serveCmd.Parse(os.Args[2:])
+		storage.Configure(*dataFile)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err := server.Run(ctx, server.Config{
+			Addr:        *addr,
+			TemplateDir: *templateDir,
+			StaticDir:   *staticDir,
+		})
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	// ---------------- USERS ----------------
+	case "user-add", "user-passwd":
+		userCmd := flag.NewFlagSet(command, flag.ExitOnError)
+		username := userCmd.String("username", "", "Username (required)")
+		role := userCmd.String("role", models.RolePlayer, "Role: player or dm (user-add only)")
+		_ = userCmd.Parse(os.Args[2:])
+		if *username == "" {
+			fmt.Println("username is required")
+			os.Exit(2)
+		}
+		password, err := readPassword()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if command == "user-add" {
+			err = commands.AddUser(*username, password, *role)
+		} else {
+			err = commands.SetPassword(*username, password)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	case "user-remove":
+		removeCmd := flag.NewFlagSet("user-remove", flag.ExitOnError)
+		username := removeCmd.String("username", "", "Username (required)")
+		_ = removeCmd.Parse(os.Args[2:])
+		if *username == "" {
+			fmt.Println("username is required")
+			os.Exit(2)
+		}
+		if err := commands.RemoveUser(*username); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+	case "user-list":
+		if err := commands.ListUsers(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
 	// ---------------- DEFAULT ----------------
 	default:
 		printUsage()
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 )
 
@@ -39,6 +40,40 @@ func (a AbilityScores) Modifier(name string) int {
 	return int(math.Floor(float64(score-10) / 2))
 }
 
+func (a *AbilityScores) Set(name string, score int) {
+	switch name {
+	case "Strength":
+		a.Strength = score
+	case "Dexterity":
+		a.Dexterity = score
+	case "Constitution":
+		a.Constitution = score
+	case "Intelligence":
+		a.Intelligence = score
+	case "Wisdom":
+		a.Wisdom = score
+	case "Charisma":
+		a.Charisma = score
+	}
+}
+
+// Add returns the per-ability sum of both score sets.
+func (a AbilityScores) Add(other AbilityScores) AbilityScores {
+	total := AbilityScores{}
+	for _, name := range abilityNames {
+		total.Set(name, a.Score(name)+other.Score(name))
+	}
+	return total
+}
+
+func AbilityScoresFromMap(scores map[string]int) AbilityScores {
+	abilities := AbilityScores{}
+	for _, name := range abilityNames {
+		abilities.Set(name, scores[name])
+	}
+	return abilities
+}
+
 // ------------------------
 // Equipment
 // ------------------------
@@ -77,24 +112,100 @@ type Spell struct {
 	Prepared bool   `json:"prepared"`
 	School   string `json:"school,omitempty"`
 	Range    string `json:"range,omitempty"`
+	// Copied marks a spell a wizard copied into the spellbook, which doesn't
+	// count toward the spells the wizard adds when gaining levels.
+	Copied bool `json:"copied,omitempty"`
+
+	// The rest of the spell card; empty for spells that only came from the
+	// local spell list.
+	CastingTime   string   `json:"casting_time,omitempty"`
+	Components    []string `json:"components,omitempty"`
+	Material      string   `json:"material,omitempty"`
+	Duration      string   `json:"duration,omitempty"`
+	Concentration bool     `json:"concentration,omitempty"`
+	Ritual        bool     `json:"ritual,omitempty"`
+	Description   string   `json:"description,omitempty"`
+	HigherLevel   string   `json:"higher_level,omitempty"`
+}
+
+// HasDetails reports whether the spell carries a full spell card.
+func (s Spell) HasDetails() bool {
+	return s.CastingTime != "" || s.Description != ""
+}
+
+// ComponentsText formats the components as on a spell card, e.g.
+// "V, S, M (a pinch of sulfur)".
+func (s Spell) ComponentsText() string {
+	text := strings.Join(s.Components, ", ")
+	if s.Material != "" {
+		text += " (" + s.Material + ")"
+	}
+	return text
+}
+
+// ------------------------
+// Proficiency
+// ------------------------
+type ProficiencyLevel int
+
+const (
+	NotProficient ProficiencyLevel = iota
+	HalfProficient
+	Proficient
+	Expertise
+)
+
+func (p ProficiencyLevel) String() string {
+	switch p {
+	case HalfProficient:
+		return "half"
+	case Proficient:
+		return "proficient"
+	case Expertise:
+		return "expertise"
+	}
+	return "none"
+}
+
+// Bonus returns the proficiency bonus a check at this level adds.
+func (p ProficiencyLevel) Bonus(proficiencyBonus int) int {
+	switch p {
+	case HalfProficient:
+		return proficiencyBonus / 2
+	case Proficient:
+		return proficiencyBonus
+	case Expertise:
+		return proficiencyBonus * 2
+	}
+	return 0
 }
 
 // ------------------------
 // Character
 // ------------------------
 type Character struct {
-	ID                 int            `json:"id"`
-	Name               string         `json:"name"`
-	PlayerName         string         `json:"player_name,omitempty"`
-	Race               string         `json:"race"`
-	Class              string         `json:"class"`
-	Level              int            `json:"level"`
-	Background         string         `json:"background"`
-	Alignment          string         `json:"alignment,omitempty"`
-	ProficiencyBonus   int            `json:"proficiency_bonus"`
-	Abilities          AbilityScores  `json:"abilities"`
-	SkillProficiencies []string       `json:"skill_proficiencies"`
-	Skills             map[string]int `json:"skills"`
+	SchemaVersion      int               `json:"schema_version"`
+	ID                 int               `json:"id"`
+	Name               string            `json:"name"`
+	PlayerName         string            `json:"player_name,omitempty"`
+	Race               string            `json:"race"`
+	Class              string            `json:"class"`
+	Level              int               `json:"level"`
+	Background         string            `json:"background"`
+	Alignment          string            `json:"alignment,omitempty"`
+	ProficiencyBonus   int               `json:"proficiency_bonus"`
+	Abilities          AbilityScores     `json:"abilities"`
+	BaseAbilities      AbilityScores     `json:"base_abilities"`
+	RacialBonuses      AbilityScores     `json:"racial_bonuses"`
+	OtherBonuses       AbilityScores     `json:"other_bonuses"`
+	SkillProficiencies []string          `json:"skill_proficiencies"`
+	SkillExpertise     []string          `json:"skill_expertise,omitempty"`
+	JackOfAllTrades    bool              `json:"jack_of_all_trades,omitempty"`
+	SkillAdvantages    map[string]string `json:"skill_advantages,omitempty"`
+	Skills             map[string]int    `json:"skills"`
+
+	SavingThrowProficiencies []string       `json:"saving_throw_proficiencies"`
+	SavingThrows             map[string]int `json:"saving_throws"`
 
 	StrengthMod     int `json:"strength_mod"`
 	DexterityMod    int `json:"dexterity_mod"`
@@ -107,10 +218,15 @@ type Character struct {
 
 	Spells     []Spell     `json:"spells,omitempty"`
 	SpellSlots map[int]int `json:"spell_slots,omitempty"`
+	// SpellSlotsUsed counts the slots of each level spent since the last rest
+	// that restores them.
+	SpellSlotsUsed map[int]int `json:"spell_slots_used,omitempty"`
 
-	ArmorClass        int `json:"armor_class"`
-	Initiative        int `json:"initiative"`
-	PassivePerception int `json:"passive_perception"`
+	ArmorClass           int `json:"armor_class"`
+	Initiative           int `json:"initiative"`
+	PassivePerception    int `json:"passive_perception"`
+	PassiveInvestigation int `json:"passive_investigation"`
+	PassiveInsight       int `json:"passive_insight"`
 
 	SpellcastingAbility string `json:"spellcasting_ability,omitempty"`
 	SpellSaveDC         int    `json:"spell_save_dc,omitempty"`
@@ -119,6 +235,7 @@ type Character struct {
 	CanPrepareSpells bool `json:"can_prepare_spells"`
 
 	ExperiencePoints   int    `json:"experience_points,omitempty"`
+	Inspiration        bool   `json:"inspiration,omitempty"`
 	Speed              int    `json:"speed,omitempty"`
 	MaxHitPoints       int    `json:"max_hit_points,omitempty"`
 	CurrentHitPoints   int    `json:"current_hit_points,omitempty"`
@@ -139,6 +256,9 @@ type Character struct {
 	Bonds          string `json:"bonds,omitempty"`
 	Flaws          string `json:"flaws,omitempty"`
 	Features       string `json:"features,omitempty"`
+
+	OtherProficiencies string `json:"other_proficiencies,omitempty"`
+	SpellNotes         string `json:"spell_notes,omitempty"`
 }
 
 // ------------------------
@@ -175,6 +295,36 @@ var ClassSkills = map[string][]string{
 	"wizard":    {"Arcana", "History", "Insight", "Religion"},
 }
 
+var ClassSavingThrows = map[string][]string{
+	"barbarian": {"Strength", "Constitution"},
+	"bard":      {"Dexterity", "Charisma"},
+	"cleric":    {"Wisdom", "Charisma"},
+	"druid":     {"Intelligence", "Wisdom"},
+	"fighter":   {"Strength", "Constitution"},
+	"monk":      {"Strength", "Dexterity"},
+	"paladin":   {"Wisdom", "Charisma"},
+	"ranger":    {"Strength", "Dexterity"},
+	"rogue":     {"Dexterity", "Intelligence"},
+	"sorcerer":  {"Constitution", "Charisma"},
+	"warlock":   {"Wisdom", "Charisma"},
+	"wizard":    {"Intelligence", "Wisdom"},
+}
+
+var ClassHitDice = map[string]int{
+	"barbarian": 12,
+	"bard":      8,
+	"cleric":    8,
+	"druid":     8,
+	"fighter":   10,
+	"monk":      8,
+	"paladin":   10,
+	"ranger":    10,
+	"rogue":     8,
+	"sorcerer":  6,
+	"warlock":   8,
+	"wizard":    6,
+}
+
 var SkillAbilities = map[string]string{
 	"Acrobatics":      "Dexterity",
 	"Animal Handling": "Wisdom",
@@ -207,14 +357,6 @@ var SpellcastingClasses = map[string]string{
 	"wizard":   "Intelligence",
 }
 
-var FullCasterSpellSlots = [][]int{
-	{2}, {3}, {4}, {4, 2}, {4, 3}, {4, 3, 2}, {4, 3, 3}, {4, 3, 3, 1},
-	{4, 3, 3, 2}, {4, 3, 3, 3}, {4, 3, 3, 3, 1}, {4, 3, 3, 3, 2}, {4, 3, 3, 3, 2, 1},
-	{4, 3, 3, 3, 2, 1}, {4, 3, 3, 3, 2, 1, 1}, {4, 3, 3, 3, 2, 1, 1},
-	{4, 3, 3, 3, 2, 1, 1, 1}, {4, 3, 3, 3, 2, 1, 1, 1}, {4, 3, 3, 3, 2, 1, 1, 1, 1},
-	{4, 3, 3, 3, 2, 1, 1, 1, 1},
-}
-
 // ------------------------
 // Constructor
 // ------------------------
@@ -222,19 +364,18 @@ func NewCharacter(id int, name, race, class, background string, level int, abili
 	raceKey := strings.ToLower(race)
 	classKey := strings.ToLower(class)
 
-	mod := RaceModifiers[raceKey]
-	var abilities AbilityScores
+	var base AbilityScores
 	if len(abilityScores) == 6 {
-		abilities = AbilityScores{
-			Strength:     abilityScores[0] + mod["Strength"],
-			Dexterity:    abilityScores[1] + mod["Dexterity"],
-			Constitution: abilityScores[2] + mod["Constitution"],
-			Intelligence: abilityScores[3] + mod["Intelligence"],
-			Wisdom:       abilityScores[4] + mod["Wisdom"],
-			Charisma:     abilityScores[5] + mod["Charisma"],
+		base = AbilityScores{
+			Strength:     abilityScores[0],
+			Dexterity:    abilityScores[1],
+			Constitution: abilityScores[2],
+			Intelligence: abilityScores[3],
+			Wisdom:       abilityScores[4],
+			Charisma:     abilityScores[5],
 		}
 	} else {
-		abilities = AssignAbilities(mod)
+		base = AssignAbilities(nil)
 	}
 
 	char := &Character{
@@ -245,30 +386,92 @@ func NewCharacter(id int, name, race, class, background string, level int, abili
 		Level:              level,
 		Background:         background,
 		ProficiencyBonus:   CalculateProfBonus(level),
-		Abilities:          abilities,
+		BaseAbilities:      base,
+		RacialBonuses:      RacialAbilityBonuses(raceKey),
 		SkillProficiencies: skillChoices,
 		Skills:             make(map[string]int),
-		Equipment:          Equipment{},
-		ArmorClass:         10,
-		Speed:              30,
-		MaxHitPoints:       10,
-		CurrentHitPoints:   10,
-	}
 
-		char.StrengthMod = abilities.Modifier("Strength")
-	char.DexterityMod = abilities.Modifier("Dexterity")
-	char.ConstitutionMod = abilities.Modifier("Constitution")
-	char.IntelligenceMod = abilities.Modifier("Intelligence")
-	char.WisdomMod = abilities.Modifier("Wisdom")
-	char.CharismaMod = abilities.Modifier("Charisma")
+		SavingThrowProficiencies: GetSavingThrowProficiencies(classKey),
+		Equipment:                Equipment{},
+		ArmorClass:               10,
+		Speed:                    30,
+		MaxHitPoints:             10,
+		CurrentHitPoints:         10,
+	}
 
+	char.CalculateAbilityScores()
+	char.CalculateAbilityModifiers()
 	char.CalculateAllSkills()
+	char.CalculateSavingThrows()
 	char.CalculateCombatStats()
 	char.SetupSpellcasting()
 
 	return char
 }
 
+// Clone returns a deep copy of the character so it can be modified without
+// affecting the original.
+func (c Character) Clone() Character {
+	clone := c
+	clone.SkillProficiencies = append([]string(nil), c.SkillProficiencies...)
+	clone.SavingThrowProficiencies = append([]string(nil), c.SavingThrowProficiencies...)
+	clone.SkillExpertise = append([]string(nil), c.SkillExpertise...)
+	if c.SkillAdvantages != nil {
+		clone.SkillAdvantages = make(map[string]string, len(c.SkillAdvantages))
+		for k, v := range c.SkillAdvantages {
+			clone.SkillAdvantages[k] = v
+		}
+	}
+	clone.Spells = append([]Spell(nil), c.Spells...)
+	if c.Skills != nil {
+		clone.Skills = make(map[string]int, len(c.Skills))
+		for k, v := range c.Skills {
+			clone.Skills[k] = v
+		}
+	}
+	if c.SavingThrows != nil {
+		clone.SavingThrows = make(map[string]int, len(c.SavingThrows))
+		for k, v := range c.SavingThrows {
+			clone.SavingThrows[k] = v
+		}
+	}
+	if c.SpellSlots != nil {
+		clone.SpellSlots = make(map[int]int, len(c.SpellSlots))
+		for k, v := range c.SpellSlots {
+			clone.SpellSlots[k] = v
+		}
+	}
+	if c.SpellSlotsUsed != nil {
+		clone.SpellSlotsUsed = make(map[int]int, len(c.SpellSlotsUsed))
+		for k, v := range c.SpellSlotsUsed {
+			clone.SpellSlotsUsed[k] = v
+		}
+	}
+	clone.Equipment = c.Equipment.Clone()
+	return clone
+}
+
+func (e Equipment) Clone() Equipment {
+	clone := Equipment{}
+	if e.MainHand != nil {
+		w := *e.MainHand
+		clone.MainHand = &w
+	}
+	if e.OffHand != nil {
+		w := *e.OffHand
+		clone.OffHand = &w
+	}
+	if e.Armor != nil {
+		a := *e.Armor
+		clone.Armor = &a
+	}
+	if e.Shield != nil {
+		s := *e.Shield
+		clone.Shield = &s
+	}
+	return clone
+}
+
 // ------------------------
 // Helpers voor spells
 // ------------------------
@@ -321,30 +524,140 @@ func (c *Character) UpdateLevel(newLevel int) {
 	c.Level = newLevel
 	c.ProficiencyBonus = CalculateProfBonus(newLevel)
 	c.CalculateAllSkills()
+	c.CalculateSavingThrows()
 	c.CalculateCombatStats()
 	c.SetupSpellcasting()
 }
 
+// RacialAbilityBonuses returns the ability score increases granted by a race.
+func RacialAbilityBonuses(race string) AbilityScores {
+	return AbilityScoresFromMap(RaceModifiers[strings.ToLower(strings.TrimSpace(race))])
+}
+
+// CalculateAbilityScores sets the total ability scores from the base scores
+// plus racial and other bonuses. Recalculating never stacks bonuses twice.
+// Characters saved before base scores existed keep their stored totals.
+func (c *Character) CalculateAbilityScores() {
+	if c.BaseAbilities == (AbilityScores{}) {
+		return
+	}
+	c.Abilities = c.BaseAbilities.Add(c.RacialBonuses).Add(c.OtherBonuses)
+}
+
+// CalculateAbilityModifiers refreshes the stored per-ability modifiers from the
+// current ability scores.
+func (c *Character) CalculateAbilityModifiers() {
+	c.StrengthMod = c.Abilities.Modifier("Strength")
+	c.DexterityMod = c.Abilities.Modifier("Dexterity")
+	c.ConstitutionMod = c.Abilities.Modifier("Constitution")
+	c.IntelligenceMod = c.Abilities.Modifier("Intelligence")
+	c.WisdomMod = c.Abilities.Modifier("Wisdom")
+	c.CharismaMod = c.Abilities.Modifier("Charisma")
+}
+
+// RecalculateDerivedStats recomputes every value that follows from level,
+// ability scores, proficiencies and equipment. Spell slots and the spell list
+// are left untouched.
+func (c *Character) RecalculateDerivedStats() {
+	if c.Level > 0 {
+		c.ProficiencyBonus = CalculateProfBonus(c.Level)
+	}
+	c.CalculateAbilityScores()
+	c.CalculateAbilityModifiers()
+	c.CalculateAllSkills()
+	c.CalculateSavingThrows()
+	c.CalculateCombatStats()
+	c.calculateSpellStats()
+}
+
 func (c *Character) CalculateAllSkills() {
 	c.Skills = make(map[string]int)
 	for skill, ability := range SkillAbilities {
 		mod := c.Abilities.Modifier(ability)
-		if contains(c.SkillProficiencies, skill) {
+		mod += c.SkillProficiencyLevel(skill).Bonus(c.ProficiencyBonus)
+		c.Skills[skill] = mod
+	}
+}
+
+// SkillProficiencyLevel combines skill proficiencies, expertise and Jack of
+// All Trades into the level that applies to a single skill.
+func (c Character) SkillProficiencyLevel(skill string) ProficiencyLevel {
+	if contains(c.SkillProficiencies, skill) {
+		if contains(c.SkillExpertise, skill) {
+			return Expertise
+		}
+		return Proficient
+	}
+	if c.HasJackOfAllTrades() {
+		return HalfProficient
+	}
+	return NotProficient
+}
+
+// HasJackOfAllTrades reports whether half proficiency applies to ability
+// checks the character isn't proficient in. Bards gain it at 2nd level.
+func (c Character) HasJackOfAllTrades() bool {
+	return c.JackOfAllTrades || (strings.ToLower(c.Class) == "bard" && c.Level >= 2)
+}
+
+// ValidateExpertise checks that every expertise skill is a known skill the
+// character is proficient in.
+func (c Character) ValidateExpertise() error {
+	for _, skill := range c.SkillExpertise {
+		if _, ok := SkillAbilities[skill]; !ok {
+			return fmt.Errorf("unknown skill %q", skill)
+		}
+		if !contains(c.SkillProficiencies, skill) {
+			return fmt.Errorf("expertise in %s requires proficiency in %s", skill, skill)
+		}
+	}
+	return nil
+}
+
+func (c *Character) CalculateSavingThrows() {
+	c.SavingThrows = make(map[string]int)
+	for _, ability := range abilityNames {
+		mod := c.Abilities.Modifier(ability)
+		if c.HasSavingThrowProficiency(ability) {
 			mod += c.ProficiencyBonus
 		}
-		c.Skills[skill] = mod
+		c.SavingThrows[ability] = mod
 	}
 }
 
+func (c Character) HasSavingThrowProficiency(ability string) bool {
+	for _, proficient := range c.SavingThrowProficiencies {
+		if strings.EqualFold(proficient, ability) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Character) CalculateCombatStats() {
 	c.Initiative = c.Abilities.Modifier("Dexterity")
-	c.PassivePerception = 10 + c.Abilities.Modifier("Wisdom")
-	if p, ok := c.Skills["Perception"]; ok {
-		c.PassivePerception = 10 + p
+	if c.HasJackOfAllTrades() {
+		c.Initiative += HalfProficient.Bonus(c.ProficiencyBonus)
 	}
+	c.PassivePerception = c.PassiveScore("Perception")
+	c.PassiveInvestigation = c.PassiveScore("Investigation")
+	c.PassiveInsight = c.PassiveScore("Insight")
 	c.CalculateArmorClass()
 }
 
+// PassiveScore is 10 plus the skill bonus, with +5 when a feature grants
+// advantage on the skill.
+func (c Character) PassiveScore(skill string) int {
+	score := 10 + c.Abilities.Modifier(SkillAbilities[skill])
+	if bonus, ok := c.Skills[skill]; ok {
+		score = 10 + bonus
+	}
+	if _, ok := c.SkillAdvantages[skill]; ok {
+		score += 5
+	}
+	return score
+}
+
 func (c *Character) CalculateArmorClass() {
 	ac := 10
 
@@ -377,6 +690,9 @@ func (c *Character) CalculateArmorClass() {
 	c.ArmorClass = ac
 }
 
+// SetupSpellcasting sets the spellcasting ability, save DC and attack bonus
+// of the character's class. Spell slots are left to commands.SetupSpellcasting,
+// which calls this.
 func (c *Character) SetupSpellcasting() {
 	if !canCastSpells(c.Class) {
 		c.SpellcastingAbility = ""
@@ -387,102 +703,91 @@ func (c *Character) SetupSpellcasting() {
 		return
 	}
 
-	if ability, ok := SpellcastingClasses[c.Class]; ok {
-		c.SpellcastingAbility = ability
-		mod := c.Abilities.Modifier(ability)
-		c.SpellSaveDC = 8 + c.ProficiencyBonus + mod
-		c.SpellAttackBonus = c.ProficiencyBonus + mod
-	}
-
+	c.calculateSpellStats()
 	c.CanPrepareSpells = isPreparedCaster(c.Class)
-	c.UpdateSpellSlots()
 }
 
-// ------------------------
-// Spell Slots
-// ------------------------
-func (c *Character) UpdateSpellSlots() {
-	if !canCastSpells(c.Class) {
-		c.SpellSlots = nil
+func (c *Character) calculateSpellStats() {
+	ability, ok := SpellcastingClasses[c.Class]
+	if !ok {
+		c.SpellcastingAbility = ""
+		c.SpellSaveDC = 0
+		c.SpellAttackBonus = 0
 		return
 	}
+	c.SpellcastingAbility = ability
+	mod := c.Abilities.Modifier(ability)
+	c.SpellSaveDC = 8 + c.ProficiencyBonus + mod
+	c.SpellAttackBonus = c.ProficiencyBonus + mod
+}
 
-	c.SpellSlots = make(map[int]int)
+// ------------------------
+// Validation
+// ------------------------
+var abilityNames = []string{"Strength", "Dexterity", "Constitution", "Intelligence", "Wisdom", "Charisma"}
 
-	switch c.Class {
-	case "wizard", "cleric", "druid", "bard", "sorcerer":
-		if c.Level <= len(FullCasterSpellSlots) {
-			for lvl, slots := range FullCasterSpellSlots[c.Level-1] {
-				c.SpellSlots[lvl+1] = slots
-			}
-		}
-	case "paladin":
-		c.SpellSlots = calculatePaladinSlots(c.Level)
-	case "ranger":
-		c.SpellSlots = calculateRangerSlots(c.Level)
-	case "warlock":
-		c.SpellSlots = calculateWarlockSlots(c.Level)
+func (a AbilityScores) Score(name string) int {
+	switch name {
+	case "Strength":
+		return a.Strength
+	case "Dexterity":
+		return a.Dexterity
+	case "Constitution":
+		return a.Constitution
+	case "Intelligence":
+		return a.Intelligence
+	case "Wisdom":
+		return a.Wisdom
+	case "Charisma":
+		return a.Charisma
 	}
+	return 0
 }
 
-// ------------------------
-// Spell Slot Helpers
-// ------------------------
-func calculatePaladinSlots(level int) map[int]int {
-	slots := map[int]int{}
-	if level >= 1 {
-		slots[1] = 4
+// Validate checks that the character is complete enough to be stored.
+func (c Character) Validate() error {
+	var problems []string
+	if strings.TrimSpace(c.Name) == "" {
+		problems = append(problems, "name is required")
 	}
-	if level >= 2 {
-		slots[2] = 3
+	if c.Level < 1 || c.Level > 20 {
+		problems = append(problems, fmt.Sprintf("level must be between 1 and 20, got %d", c.Level))
 	}
-	if level >= 3 {
-		slots[3] = 3
+	for _, name := range abilityNames {
+		if score := c.BaseAbilities.Score(name); score < 1 || score > 30 {
+			problems = append(problems, fmt.Sprintf("base %s must be between 1 and 30, got %d", strings.ToLower(name), score))
+		}
+		if score := c.Abilities.Score(name); score < 1 || score > 30 {
+			problems = append(problems, fmt.Sprintf("%s must be between 1 and 30, got %d", strings.ToLower(name), score))
+		}
 	}
-	if level >= 4 {
-		slots[4] = 3
+	for _, skill := range c.SkillProficiencies {
+		if _, ok := SkillAbilities[skill]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown skill %q", skill))
+		}
 	}
-	if level >= 5 {
-		slots[5] = 2
+	if err := c.ValidateExpertise(); err != nil {
+		problems = append(problems, err.Error())
 	}
-	return slots
-}
-
-func calculateRangerSlots(level int) map[int]int {
-	slots := map[int]int{}
-	slots[1] = (level + 1) / 2
-	if level >= 4 {
-		slots[2] = level / 2
+	for skill := range c.SkillAdvantages {
+		if _, ok := SkillAbilities[skill]; !ok {
+			problems = append(problems, fmt.Sprintf("unknown skill %q", skill))
+		}
 	}
-	return slots
-}
-
-func calculateWarlockSlots(level int) map[int]int {
-	slots := map[int]int{}
-
-	slots[0] = 4
-
-	var pactLevel, numSlots int
-	switch {
-	case level >= 1 && level <= 1:
-		numSlots = 1
-		pactLevel = 1
-	case level >= 2 && level <= 8:
-		numSlots = 2
-		pactLevel = 1
-	case level >= 9 && level <= 11:
-		numSlots = 3
-		pactLevel = 2
-	case level >= 12 && level <= 16:
-		numSlots = 3
-		pactLevel = 3
-	case level >= 17 && level <= 20:
-		numSlots = 4
-		pactLevel = 5
+	for _, ability := range c.SavingThrowProficiencies {
+		if !contains(abilityNames, ability) {
+			problems = append(problems, fmt.Sprintf("unknown saving throw %q", ability))
+		}
 	}
-
-	slots[pactLevel] = numSlots
-	return slots
+	for _, spell := range c.Spells {
+		if spell.Name == "" || spell.Level < 0 || spell.Level > 9 {
+			problems = append(problems, fmt.Sprintf("invalid spell %q (level %d)", spell.Name, spell.Level))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid character: %s", strings.Join(problems, "; "))
+	}
+	return nil
 }
 
 // ------------------------
@@ -495,6 +800,32 @@ func GetAvailableSkills(className string) []string {
 	return []string{}
 }
 
+// NormalizeAbilityName maps "str", "STR" or "strength" to "Strength".
+func NormalizeAbilityName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for _, ability := range abilityNames {
+		if strings.EqualFold(ability, name) || strings.EqualFold(ability[:3], name) {
+			return ability, true
+		}
+	}
+	return name, false
+}
+
+// NormalizeSkillName maps a case-insensitive skill name to its canonical form.
+func NormalizeSkillName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for skill := range SkillAbilities {
+		if strings.EqualFold(skill, name) {
+			return skill, true
+		}
+	}
+	return name, false
+}
+
+func GetSavingThrowProficiencies(className string) []string {
+	return append([]string(nil), ClassSavingThrows[strings.ToLower(className)]...)
+}
+
 func FormatModifier(mod int) string {
 	if mod >= 0 {
 		return fmt.Sprintf("+%d", mod)
@@ -510,3 +841,40 @@ func contains(slice []string, val string) bool {
 	}
 	return false
 }
+
+// HitDie returns the size of the class hit die, defaulting to a d8.
+func (c Character) HitDie() int {
+	if die, ok := ClassHitDice[strings.ToLower(c.Class)]; ok {
+		return die
+	}
+	return 8
+}
+
+// RemainingHitDice parses HitDiceRemaining ("3d8" or "3"). Characters that
+// have never tracked hit dice have one per level.
+func (c Character) RemainingHitDice() int {
+	raw := strings.TrimSpace(c.HitDiceRemaining)
+	if raw == "" {
+		return c.Level
+	}
+	if i := strings.IndexAny(raw, "dD"); i >= 0 {
+		raw = raw[:i]
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || count < 0 {
+		return c.Level
+	}
+	return count
+}
+
+// RemainingSpellSlots is how many slots of the given level the character
+// has left.
+func (c Character) RemainingSpellSlots(level int) int {
+	return max(c.SpellSlots[level]-c.SpellSlotsUsed[level], 0)
+}
+
+// SetRemainingHitDice stores count as "NdX" and fills in the total.
+func (c *Character) SetRemainingHitDice(count int) {
+	c.HitDiceTotal = fmt.Sprintf("%dd%d", c.Level, c.HitDie())
+	c.HitDiceRemaining = fmt.Sprintf("%dd%d", count, c.HitDie())
+}
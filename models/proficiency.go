@@ -0,0 +1,93 @@
+package models
+
+import "strings"
+
+// ClassArmorProficiencies lists the armor categories ("light", "medium",
+// "heavy") each class is trained with, and "shield" when it can use one.
+var ClassArmorProficiencies = map[string][]string{
+	"barbarian": {"light", "medium", "shield"},
+	"bard":      {"light"},
+	"cleric":    {"light", "medium", "shield"},
+	"druid":     {"light", "medium", "shield"},
+	"fighter":   {"light", "medium", "heavy", "shield"},
+	"monk":      {},
+	"paladin":   {"light", "medium", "heavy", "shield"},
+	"ranger":    {"light", "medium", "shield"},
+	"rogue":     {"light"},
+	"sorcerer":  {},
+	"warlock":   {"light"},
+	"wizard":    {},
+}
+
+// ClassWeaponProficiencies lists the weapon categories ("simple", "martial")
+// and single weapons each class is trained with.
+var ClassWeaponProficiencies = map[string][]string{
+	"barbarian": {"simple", "martial"},
+	"bard":      {"simple", "crossbow, hand", "longsword", "rapier", "shortsword"},
+	"cleric":    {"simple"},
+	"druid":     {"club", "dagger", "dart", "javelin", "mace", "quarterstaff", "scimitar", "sickle", "sling", "spear"},
+	"fighter":   {"simple", "martial"},
+	"monk":      {"simple", "shortsword"},
+	"paladin":   {"simple", "martial"},
+	"ranger":    {"simple", "martial"},
+	"rogue":     {"simple", "crossbow, hand", "longsword", "rapier", "shortsword"},
+	"sorcerer":  {"dagger", "dart", "sling", "quarterstaff", "crossbow, light"},
+	"warlock":   {"simple"},
+	"wizard":    {"dagger", "dart", "sling", "quarterstaff", "crossbow, light"},
+}
+
+// RaceWeaponProficiencies lists the weapons a race is trained with on top of
+// its class.
+var RaceWeaponProficiencies = map[string][]string{
+	"dwarf":      {"battleaxe", "handaxe", "light hammer", "warhammer"},
+	"hill dwarf": {"battleaxe", "handaxe", "light hammer", "warhammer"},
+	"elf":        {"longsword", "shortsword", "shortbow", "longbow"},
+}
+
+// Category returns "light", "medium" or "heavy", going by how much of the
+// Dexterity modifier the armor allows.
+func (a Armor) Category() string {
+	switch {
+	case !a.DexBonus:
+		return "heavy"
+	case a.MaxDexBonus > 0:
+		return "medium"
+	}
+	return "light"
+}
+
+// IsMartial reports whether the weapon needs martial weapon training.
+func (w Weapon) IsMartial() bool {
+	return strings.HasPrefix(w.Category, "martial")
+}
+
+// ProficientWithArmor reports whether the character's class is trained with
+// the armor's category.
+func (c Character) ProficientWithArmor(a Armor) bool {
+	return contains(ClassArmorProficiencies[strings.ToLower(c.Class)], a.Category())
+}
+
+// ProficientWithShield reports whether the character's class can use a
+// shield.
+func (c Character) ProficientWithShield() bool {
+	return contains(ClassArmorProficiencies[strings.ToLower(c.Class)], "shield")
+}
+
+// ProficientWithWeapon reports whether the character's class or race is
+// trained with the weapon, by category or by name.
+func (c Character) ProficientWithWeapon(w Weapon) bool {
+	category := "simple"
+	if w.IsMartial() {
+		category = "martial"
+	}
+	name := strings.ToLower(w.Name)
+	for _, proficiencies := range [][]string{
+		ClassWeaponProficiencies[strings.ToLower(c.Class)],
+		RaceWeaponProficiencies[strings.ToLower(c.Race)],
+	} {
+		if contains(proficiencies, category) || contains(proficiencies, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package models
+
+import "strings"
+
+// Roles a web server account can have.
+const (
+	RolePlayer = "player"
+	RoleDM     = "dm"
+)
+
+// User is a web server account. Players own the characters whose PlayerName
+// is their username; DMs can see and edit every character.
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+func (u User) IsDM() bool {
+	return u.Role == RoleDM
+}
+
+// CanEdit reports whether u may see and change c.
+func (u User) CanEdit(c Character) bool {
+	return u.IsDM() || strings.EqualFold(c.PlayerName, u.Username)
+}
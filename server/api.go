@@ -0,0 +1,583 @@
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dnd-character-sheet/commands"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+// openAPISpec describes every route in apiRoutes. Update it together with the
+// handlers and request types below.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// maxRequestBody limits the size of JSON request bodies.
+const maxRequestBody = 1 << 20
+
+// apiRoutes lists the JSON API. Handlers call the same commands as the CLI,
+// so rules and change history are shared.
+var apiRoutes = []struct {
+	pattern string
+	handler http.HandlerFunc
+}{
+	{"GET /api/openapi.json", apiOpenAPISpec},
+	{"GET /api/characters", apiListCharacters},
+	{"POST /api/characters", apiCreateCharacter},
+	{"GET /api/characters/{id}", apiGetCharacter},
+	{"PUT /api/characters/{id}", apiReplaceCharacter},
+	{"PATCH /api/characters/{id}", apiPatchCharacter},
+	{"DELETE /api/characters/{id}", apiDeleteCharacter},
+	{"POST /api/characters/{id}/equip", apiEquip},
+	{"POST /api/characters/{id}/learn-spell", apiLearnSpell},
+	{"POST /api/characters/{id}/prepare-spell", apiPrepareSpell},
+	{"POST /api/characters/{id}/unprepare-spell", apiUnprepareSpell},
+	{"POST /api/characters/{id}/cast-spell", apiCastSpell},
+	{"POST /api/characters/{id}/copy-spell", apiCopySpell},
+	{"POST /api/characters/{id}/damage", apiDamage},
+	{"POST /api/characters/{id}/heal", apiHeal},
+	{"POST /api/characters/{id}/rest", apiRest},
+}
+
+// registerAPIRoutes adds the JSON API to mux.
+func registerAPIRoutes(mux *http.ServeMux) {
+	for _, route := range apiRoutes {
+		mux.HandleFunc(route.pattern, route.handler)
+	}
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type createCharacterRequest struct {
+	Name               string   `json:"name"`
+	PlayerName         string   `json:"player_name"`
+	Race               string   `json:"race"`
+	Class              string   `json:"class"`
+	Background         string   `json:"background"`
+	Level              int      `json:"level"`
+	AbilityScores      []int    `json:"ability_scores"`
+	SkillProficiencies []string `json:"skill_proficiencies"`
+	SkillExpertise     []string `json:"skill_expertise"`
+}
+
+type equipRequest struct {
+	Weapon string `json:"weapon"`
+	Slot   string `json:"slot"`
+	Armor  string `json:"armor"`
+	Shield string `json:"shield"`
+}
+
+type spellRequest struct {
+	Spell   string   `json:"spell"`
+	Spells  []string `json:"spells"`
+	Replace bool     `json:"replace"`
+	Level   int      `json:"level"`
+	Ritual  bool     `json:"ritual"`
+}
+
+// names returns spell followed by spells.
+func (r spellRequest) names() []string {
+	if r.Spell == "" {
+		return r.Spells
+	}
+	return append([]string{r.Spell}, r.Spells...)
+}
+
+type hitPointsRequest struct {
+	Amount int `json:"amount"`
+}
+
+type restRequest struct {
+	Type    string `json:"type"`
+	HitDice int    `json:"hit_dice"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: message})
+}
+
+// writeCommandError reports an error returned by a command. Unknown or
+// duplicate characters have their own status and a rule the request broke is
+// a 422; anything else, such as failing to save, is the server's fault.
+func writeCommandError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrCharacterNotFound):
+		writeAPIError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrCharacterExists):
+		writeAPIError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, commands.ErrInvalid):
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+	default:
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// decodeJSON reads the request body into target, rejecting unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// readOnlyCharacterFields are the Character properties openapi.json marks
+// readOnly. They are recalculated on save, so a PATCH can't set them.
+var readOnlyCharacterFields = map[string]bool{
+	"schema_version":        true,
+	"id":                    true,
+	"proficiency_bonus":     true,
+	"abilities":             true,
+	"racial_bonuses":        true,
+	"skills":                true,
+	"saving_throws":         true,
+	"strength_mod":          true,
+	"dexterity_mod":         true,
+	"constitution_mod":      true,
+	"intelligence_mod":      true,
+	"wisdom_mod":            true,
+	"charisma_mod":          true,
+	"spell_slots":           true,
+	"armor_class":           true,
+	"initiative":            true,
+	"passive_perception":    true,
+	"passive_investigation": true,
+	"passive_insight":       true,
+	"spellcasting_ability":  true,
+	"spell_save_dc":         true,
+	"spell_attack_bonus":    true,
+	"can_prepare_spells":    true,
+}
+
+// decodePatch reads a PATCH body into character like decodeJSON, and rejects
+// it with 422 when it sets read-only properties, which saving would silently
+// recalculate.
+func decodePatch(w http.ResponseWriter, r *http.Request, character *models.Character) bool {
+	var fields map[string]json.RawMessage
+	if !decodeJSON(w, r, &fields) {
+		return false
+	}
+	var readOnly []string
+	for name := range fields {
+		if readOnlyCharacterFields[name] {
+			readOnly = append(readOnly, name)
+		}
+	}
+	if len(readOnly) > 0 {
+		sort.Strings(readOnly)
+		writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("read-only fields can't be set: %s", strings.Join(readOnly, ", ")))
+		return false
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(character); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// characterFromPath looks up the character named by the {id} path value and
+// writes the error response when there is none or it belongs to another
+// player.
+func characterFromPath(w http.ResponseWriter, r *http.Request) (models.Character, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid character id %q", r.PathValue("id")))
+		return models.Character{}, false
+	}
+
+	character, err := storage.GetCharacterByID(id)
+	if errors.Is(err, storage.ErrCharacterNotFound) {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("character %d not found", id))
+		return models.Character{}, false
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return models.Character{}, false
+	}
+	if !currentUser(r).CanEdit(character) {
+		writeAPIError(w, http.StatusForbidden, fmt.Sprintf("character %d belongs to another player", id))
+		return models.Character{}, false
+	}
+	return character, true
+}
+
+// writeCharacter responds with the stored state of the character with id.
+func writeCharacter(w http.ResponseWriter, status, id int) {
+	character, err := storage.GetCharacterByID(id)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, status, character)
+}
+
+func apiOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
+
+func apiListCharacters(w http.ResponseWriter, r *http.Request) {
+	characters, err := storage.LoadCharacters()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	user := currentUser(r)
+	list := make([]models.Character, 0, len(characters))
+	for _, character := range characters {
+		if user.CanEdit(character) {
+			list = append(list, character)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func apiCreateCharacter(w http.ResponseWriter, r *http.Request) {
+	var request createCharacterRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" || request.Race == "" || request.Class == "" {
+		writeAPIError(w, http.StatusUnprocessableEntity, "name, race and class are required")
+		return
+	}
+	if request.Level == 0 {
+		request.Level = 1
+	}
+	if request.Level < 1 || request.Level > 20 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "level must be between 1 and 20")
+		return
+	}
+	if user := currentUser(r); !user.IsDM() {
+		request.PlayerName = user.Username
+	}
+	if request.AbilityScores != nil && len(request.AbilityScores) != 6 {
+		writeAPIError(w, http.StatusUnprocessableEntity, "ability_scores must list six scores")
+		return
+	}
+
+	err := commands.CreateCharacter(
+		request.Name,
+		request.PlayerName,
+		strings.ToLower(request.Race),
+		strings.ToLower(request.Class),
+		request.Background,
+		request.Level,
+		request.AbilityScores,
+		request.SkillProficiencies,
+		request.SkillExpertise,
+	)
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+
+	character, err := storage.GetCharacterByName(request.Name)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/characters/%d", character.ID))
+	writeJSON(w, http.StatusCreated, character)
+}
+
+func apiGetCharacter(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, character)
+}
+
+func apiReplaceCharacter(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var replacement models.Character
+	if !decodeJSON(w, r, &replacement) {
+		return
+	}
+	keepOwner(r, character, &replacement)
+	if err := commands.UpdateCharacter(character.Name, replacement); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+// apiPatchCharacter applies the fields present in the body on top of the
+// stored character. Objects are merged; arrays and scalars are replaced.
+// Derived fields are read-only.
+func apiPatchCharacter(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	patched := character.Clone()
+	if !decodePatch(w, r, &patched) {
+		return
+	}
+	keepOwner(r, character, &patched)
+	if err := commands.UpdateCharacter(character.Name, patched); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+// keepOwner stops players from handing their characters to someone else.
+func keepOwner(r *http.Request, current models.Character, updated *models.Character) {
+	if !currentUser(r).IsDM() {
+		updated.PlayerName = current.PlayerName
+	}
+}
+
+func apiDeleteCharacter(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := commands.DeleteCharacter(character.Name); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func apiEquip(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request equipRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	var err error
+	switch {
+	case request.Weapon != "":
+		weapon, found := commands.Weapons[strings.ToLower(request.Weapon)]
+		if !found {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("weapon '%s' not found", request.Weapon))
+			return
+		}
+		_, err = commands.AddWeaponToSlot(character.Name, weapon, request.Slot)
+	case request.Armor != "":
+		armor, found := commands.Armors[strings.ToLower(request.Armor)]
+		if !found {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("armor '%s' not found", request.Armor))
+			return
+		}
+		_, err = commands.AddArmor(character.Name, armor.Name)
+	case request.Shield != "":
+		shield, found := commands.Shields[strings.ToLower(request.Shield)]
+		if !found {
+			writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("shield '%s' not found", request.Shield))
+			return
+		}
+		err = commands.AddShield(character.Name, shield.Name)
+	default:
+		writeAPIError(w, http.StatusBadRequest, "provide one of weapon, armor or shield")
+		return
+	}
+	if err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiLearnSpell(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request spellRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Spell == "" {
+		writeAPIError(w, http.StatusBadRequest, "spell is required")
+		return
+	}
+	if _, err := commands.LearnSpell(character.Name, request.Spell); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiPrepareSpell(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request spellRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if len(request.names()) == 0 && !request.Replace {
+		writeAPIError(w, http.StatusBadRequest, "spell or spells is required")
+		return
+	}
+	if _, err := commands.PrepareSpell(character.Name, request.names(), request.Replace); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiUnprepareSpell(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request spellRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if len(request.names()) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "spell or spells is required")
+		return
+	}
+	if _, err := commands.UnprepareSpell(character.Name, request.names()); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiCastSpell(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request spellRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Spell == "" {
+		writeAPIError(w, http.StatusBadRequest, "spell is required")
+		return
+	}
+	if _, err := commands.CastSpell(character.Name, request.Spell, request.Level, request.Ritual); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiCopySpell(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request spellRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Spell == "" {
+		writeAPIError(w, http.StatusBadRequest, "spell is required")
+		return
+	}
+	if _, err := commands.CopySpell(character.Name, request.Spell); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiDamage(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request hitPointsRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if _, err := commands.DamageCharacter(character.Name, request.Amount); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiHeal(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request hitPointsRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if _, err := commands.HealCharacter(character.Name, request.Amount); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
+
+func apiRest(w http.ResponseWriter, r *http.Request) {
+	character, ok := characterFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var request restRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if _, err := commands.RestCharacter(character.Name, request.Type, request.HitDice); err != nil {
+		writeCommandError(w, err)
+		return
+	}
+	writeCharacter(w, http.StatusOK, character.ID)
+}
@@ -0,0 +1,514 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"dnd-character-sheet/api"
+	"dnd-character-sheet/commands"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+// newTestServer serves the web UI and API from a temporary directory with a
+// DM account "dm" and a player account "pat", both with password "password1".
+// The SRD API is unreachable.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	storage.Configure(filepath.Join(dir, "characters.json"))
+	api.DefaultClient = api.NewClient("http://127.0.0.1:1")
+	api.DefaultClient.MaxRetries = 0
+	api.CacheFilePath = filepath.Join(dir, "srd-catalog.json")
+	if err := commands.LoadCatalog(); err != nil {
+		t.Fatal(err)
+	}
+	for username, role := range map[string]string{"dm": models.RoleDM, "pat": models.RolePlayer} {
+		if err := commands.AddUser(username, "password1", role); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handler, err := NewHandler(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// openAPIDoc is the part of openapi.json the tests check responses against.
+type openAPIDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas   map[string]*schema   `json:"schemas"`
+		Responses map[string]*response `json:"responses"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type response struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 schemaType         `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	ReadOnly             bool               `json:"readOnly"`
+}
+
+// schemaType is a type name or a list of them, such as ["array", "null"].
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaType{name}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+func loadOpenAPIDoc(t *testing.T) *openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return &doc
+}
+
+// operation returns the operation documented for method and a request path
+// such as /api/characters/3/heal, and the path template it matched.
+func (d *openAPIDoc) operation(method, path string) (*operation, string, error) {
+	for template, item := range d.Paths {
+		pattern := "^" + regexp.MustCompile(`\\\{[^}]+\\\}`).ReplaceAllString(regexp.QuoteMeta(template), "[^/]+") + "$"
+		if !regexp.MustCompile(pattern).MatchString(path) {
+			continue
+		}
+		raw, ok := item[strings.ToLower(method)]
+		if !ok {
+			return nil, "", fmt.Errorf("%s %s is not documented", method, template)
+		}
+		var op operation
+		if err := json.Unmarshal(raw, &op); err != nil {
+			return nil, "", err
+		}
+		return &op, template, nil
+	}
+	return nil, "", fmt.Errorf("no path in the spec matches %s", path)
+}
+
+func (d *openAPIDoc) resolve(s *schema) *schema {
+	for s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// validate returns where value doesn't match s, one message per mismatch.
+func (d *openAPIDoc) validate(value interface{}, s *schema, at string) []string {
+	s = d.resolve(s)
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		return []string{fmt.Sprintf("%s: %s is not of type %v", at, jsonText(value), []string(s.Type))}
+	}
+	var problems []string
+	if len(s.Enum) > 0 && !containsValue(s.Enum, value) {
+		problems = append(problems, fmt.Sprintf("%s: %s is not one of %v", at, jsonText(value), s.Enum))
+	}
+
+	switch value := value.(type) {
+	case float64:
+		if s.Minimum != nil && value < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is below the minimum %v", at, value, *s.Minimum))
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: %v is above the maximum %v", at, value, *s.Maximum))
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			problems = append(problems, fmt.Sprintf("%s: %d items, fewer than %d", at, len(value), *s.MinItems))
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			problems = append(problems, fmt.Sprintf("%s: %d items, more than %d", at, len(value), *s.MaxItems))
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				problems = append(problems, d.validate(item, s.Items, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %s", at, name))
+			}
+		}
+		var additional *schema
+		allowAdditional := len(s.AdditionalProperties) == 0 || string(s.AdditionalProperties) == "true"
+		if !allowAdditional && string(s.AdditionalProperties) != "false" {
+			additional = &schema{}
+			if err := json.Unmarshal(s.AdditionalProperties, additional); err != nil {
+				return append(problems, fmt.Sprintf("%s: additionalProperties: %v", at, err))
+			}
+		}
+		for _, name := range sortedKeys(value) {
+			switch property, ok := s.Properties[name]; {
+			case ok:
+				problems = append(problems, d.validate(value[name], property, at+"."+name)...)
+			case additional != nil:
+				problems = append(problems, d.validate(value[name], additional, at+"."+name)...)
+			case !allowAdditional:
+				problems = append(problems, fmt.Sprintf("%s: property %s is not documented", at, name))
+			}
+		}
+	}
+	return problems
+}
+
+func (t schemaType) matches(value interface{}) bool {
+	for _, name := range t {
+		switch value := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case float64:
+			if name == "number" || name == "integer" && value == float64(int64(value)) {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func jsonText(value interface{}) string {
+	data, _ := json.Marshal(value)
+	if len(data) > 60 {
+		return string(data[:60]) + "..."
+	}
+	return string(data)
+}
+
+// specClient sends API requests and fails the test when the response status
+// isn't documented for the route or the body doesn't match its schema. It
+// notes the operations that succeeded.
+type specClient struct {
+	t         *testing.T
+	baseURL   string
+	doc       *openAPIDoc
+	succeeded map[string]bool
+}
+
+func (c *specClient) call(user, method, path string, body interface{}, wantStatus int) map[string]interface{} {
+	c.t.Helper()
+	var reader io.Reader
+	if raw, ok := body.(string); ok {
+		reader = strings.NewReader(raw)
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if user != "" {
+		req.SetBasicAuth(user, "password1")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	if resp.StatusCode != wantStatus {
+		c.t.Errorf("%s %s as %q: status %d, want %d: %s", method, path, user, resp.StatusCode, wantStatus, data)
+	}
+	op, template, err := c.doc.operation(method, path)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	documented := op.Responses[fmt.Sprint(resp.StatusCode)]
+	if documented == nil {
+		c.t.Errorf("%s %s: status %d is not documented for %s", method, path, resp.StatusCode, op.OperationID)
+		return nil
+	}
+	for documented.Ref != "" {
+		documented = c.doc.Components.Responses[strings.TrimPrefix(documented.Ref, "#/components/responses/")]
+	}
+	if resp.StatusCode < 300 {
+		c.succeeded[method+" "+template] = true
+	}
+
+	content, ok := documented.Content["application/json"]
+	if !ok {
+		if len(data) > 0 {
+			c.t.Errorf("%s %s: status %d has no documented body, got %s", method, path, resp.StatusCode, data)
+		}
+		return nil
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/json" {
+		c.t.Errorf("%s %s: Content-Type %q, want application/json", method, path, contentType)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		c.t.Errorf("%s %s: invalid JSON: %v", method, path, err)
+		return nil
+	}
+	for _, problem := range c.doc.validate(value, content.Schema, "body") {
+		c.t.Errorf("%s %s: status %d: %s", method, path, resp.StatusCode, problem)
+	}
+	object, _ := value.(map[string]interface{})
+	return object
+}
+
+func TestAPIRoutesMatchSpec(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	for _, route := range apiRoutes {
+		if !documented[route.pattern] {
+			t.Errorf("route %s is not in openapi.json", route.pattern)
+		}
+		delete(documented, route.pattern)
+	}
+	for route := range documented {
+		t.Errorf("openapi.json documents %s, which has no handler", route)
+	}
+}
+
+// TestAPIRequestTypesMatchSpec compares the JSON fields of the request and
+// model types with the properties of their schemas.
+func TestAPIRequestTypesMatchSpec(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	types := map[string]interface{}{
+		"AbilityScores":          models.AbilityScores{},
+		"Weapon":                 models.Weapon{},
+		"Armor":                  models.Armor{},
+		"Shield":                 models.Shield{},
+		"Equipment":              models.Equipment{},
+		"Spell":                  models.Spell{},
+		"Character":              models.Character{},
+		"CreateCharacterRequest": createCharacterRequest{},
+		"EquipRequest":           equipRequest{},
+		"SpellRequest":           spellRequest{},
+		"HitPointsRequest":       hitPointsRequest{},
+		"RestRequest":            restRequest{},
+		"Error":                  apiError{},
+	}
+	for name, value := range types {
+		s, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		fields := map[string]bool{}
+		typ := reflect.TypeOf(value)
+		for i := 0; i < typ.NumField(); i++ {
+			field := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if field == "" || field == "-" {
+				continue
+			}
+			fields[field] = true
+			if _, ok := s.Properties[field]; !ok {
+				t.Errorf("%s.%s is not a property of schema %s", typ.Name(), field, name)
+			}
+		}
+		for property := range s.Properties {
+			if !fields[property] {
+				t.Errorf("schema %s documents %s, which %s doesn't have", name, property, typ.Name())
+			}
+		}
+	}
+}
+
+func TestReadOnlyFieldsMatchSpec(t *testing.T) {
+	doc := loadOpenAPIDoc(t)
+	for property, s := range doc.Components.Schemas["Character"].Properties {
+		if s.ReadOnly != readOnlyCharacterFields[property] {
+			t.Errorf("%s is readOnly %v in openapi.json but not in readOnlyCharacterFields", property, s.ReadOnly)
+		}
+	}
+	for field := range readOnlyCharacterFields {
+		if _, ok := doc.Components.Schemas["Character"].Properties[field]; !ok {
+			t.Errorf("read-only field %s is not a Character property", field)
+		}
+	}
+}
+
+func TestWriteCommandError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("%w: Ada", storage.ErrCharacterNotFound), http.StatusNotFound},
+		{fmt.Errorf("%w: Ada", storage.ErrCharacterExists), http.StatusConflict},
+		{fmt.Errorf("level 0: %w", commands.ErrInvalid), http.StatusUnprocessableEntity},
+		{fmt.Errorf("cannot save character: %w", errors.New("disk full")), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		writeCommandError(recorder, test.err)
+		if recorder.Code != test.status {
+			t.Errorf("%v: status %d, want %d", test.err, recorder.Code, test.status)
+		}
+	}
+}
+
+// TestAPIResponsesMatchSpec calls every route, for success and for the errors
+// the spec documents, and checks the responses against openapi.json.
+func TestAPIResponsesMatchSpec(t *testing.T) {
+	srv := newTestServer(t)
+	c := &specClient{t: t, baseURL: srv.URL, doc: loadOpenAPIDoc(t), succeeded: map[string]bool{}}
+
+	c.call("dm", "GET", "/api/openapi.json", nil, http.StatusOK)
+	c.call("", "GET", "/api/characters", nil, http.StatusUnauthorized)
+	c.call("dm", "GET", "/api/characters", nil, http.StatusOK)
+
+	cleric := c.call("dm", "POST", "/api/characters", map[string]interface{}{
+		"name": "Ada", "race": "human", "class": "cleric", "level": 3,
+		"ability_scores": []int{10, 12, 14, 10, 16, 8},
+	}, http.StatusCreated)
+	c.call("dm", "POST", "/api/characters", map[string]interface{}{"name": "Ada", "race": "human", "class": "cleric"}, http.StatusConflict)
+	c.call("dm", "POST", "/api/characters", map[string]interface{}{"name": "Bob", "race": "human"}, http.StatusUnprocessableEntity)
+	c.call("dm", "POST", "/api/characters", map[string]interface{}{"nickname": "Bob"}, http.StatusBadRequest)
+	wizard := c.call("pat", "POST", "/api/characters", map[string]interface{}{
+		"name": "Wren", "race": "elf", "class": "wizard", "level": 3,
+		"ability_scores": []int{8, 14, 12, 16, 12, 10},
+	}, http.StatusCreated)
+	bard := c.call("pat", "POST", "/api/characters", map[string]interface{}{"name": "Lute", "race": "human", "class": "bard"}, http.StatusCreated)
+	if cleric == nil || wizard == nil || bard == nil {
+		t.Fatal("creating the test characters failed")
+	}
+	ada := fmt.Sprintf("/api/characters/%v", cleric["id"])
+	wren := fmt.Sprintf("/api/characters/%v", wizard["id"])
+	lute := fmt.Sprintf("/api/characters/%v", bard["id"])
+
+	c.call("dm", "GET", ada, nil, http.StatusOK)
+	c.call("pat", "GET", ada, nil, http.StatusForbidden)
+	c.call("dm", "GET", "/api/characters/999", nil, http.StatusNotFound)
+	c.call("dm", "GET", "/api/characters/ada", nil, http.StatusBadRequest)
+
+	c.call("dm", "PATCH", ada, map[string]interface{}{"alignment": "lawful good", "gold_pieces": 40}, http.StatusOK)
+	c.call("dm", "PATCH", ada, map[string]interface{}{"level": 0}, http.StatusUnprocessableEntity)
+	c.call("dm", "PATCH", ada, map[string]interface{}{"abilities": map[string]int{"strength": 20}}, http.StatusUnprocessableEntity)
+	if patched := c.call("dm", "PATCH", ada, map[string]interface{}{"base_abilities": map[string]int{"strength": 14}}, http.StatusOK); patched != nil {
+		if abilities, _ := patched["abilities"].(map[string]interface{}); abilities["strength"] != 15.0 {
+			t.Errorf("abilities after patching base Strength 14 on a human: %v", patched["abilities"])
+		}
+	}
+	c.call("dm", "PATCH", ada, map[string]interface{}{"name": "Wren"}, http.StatusConflict)
+	c.call("dm", "PATCH", ada, `{"level": "three"}`, http.StatusBadRequest)
+	replacement := c.call("dm", "GET", ada, nil, http.StatusOK)
+	replacement["background"] = "sage"
+	c.call("dm", "PUT", ada, replacement, http.StatusOK)
+
+	c.call("dm", "POST", ada+"/equip", map[string]interface{}{"weapon": "mace"}, http.StatusOK)
+	c.call("dm", "POST", ada+"/equip", map[string]interface{}{"shield": "shield"}, http.StatusOK)
+	c.call("dm", "POST", ada+"/equip", map[string]interface{}{"armor": "mithril plate"}, http.StatusUnprocessableEntity)
+	c.call("dm", "POST", ada+"/equip", map[string]interface{}{}, http.StatusBadRequest)
+
+	c.call("dm", "POST", ada+"/prepare-spell", map[string]interface{}{"spells": []string{"bless", "cure wounds"}}, http.StatusOK)
+	c.call("dm", "POST", ada+"/cast-spell", map[string]interface{}{"spell": "bless"}, http.StatusOK)
+	c.call("dm", "POST", ada+"/cast-spell", map[string]interface{}{"spell": "bless", "level": 3}, http.StatusUnprocessableEntity)
+	c.call("dm", "POST", ada+"/unprepare-spell", map[string]interface{}{"spell": "cure wounds"}, http.StatusOK)
+	c.call("dm", "POST", ada+"/learn-spell", map[string]interface{}{"spell": "bless"}, http.StatusUnprocessableEntity)
+	c.call("dm", "POST", ada+"/copy-spell", map[string]interface{}{"spell": "bless"}, http.StatusUnprocessableEntity)
+
+	c.call("pat", "POST", wren+"/learn-spell", map[string]interface{}{"spell": "fire bolt"}, http.StatusUnprocessableEntity)
+	c.call("pat", "PATCH", wren, map[string]interface{}{"gold_pieces": 100}, http.StatusOK)
+	c.call("pat", "POST", wren+"/copy-spell", map[string]interface{}{"spell": "shield"}, http.StatusOK)
+	c.call("pat", "POST", lute+"/learn-spell", map[string]interface{}{"spell": "vicious mockery"}, http.StatusOK)
+	c.call("pat", "POST", lute+"/learn-spell", map[string]interface{}{}, http.StatusBadRequest)
+
+	c.call("dm", "POST", ada+"/damage", map[string]interface{}{"amount": 5}, http.StatusOK)
+	c.call("dm", "POST", ada+"/heal", map[string]interface{}{"amount": 2}, http.StatusOK)
+	c.call("dm", "POST", ada+"/heal", map[string]interface{}{"amount": -2}, http.StatusUnprocessableEntity)
+	c.call("dm", "POST", ada+"/rest", map[string]interface{}{"type": "long"}, http.StatusOK)
+	c.call("dm", "POST", ada+"/rest", map[string]interface{}{"type": "nap"}, http.StatusUnprocessableEntity)
+
+	c.call("pat", "DELETE", ada, nil, http.StatusForbidden)
+	c.call("dm", "DELETE", ada, nil, http.StatusNoContent)
+	c.call("dm", "DELETE", ada, nil, http.StatusNotFound)
+
+	for _, route := range apiRoutes {
+		if !c.succeeded[route.pattern] {
+			t.Errorf("no successful call of %s was checked", route.pattern)
+		}
+	}
+}
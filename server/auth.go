@@ -0,0 +1,258 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dnd-character-sheet/commands"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+const (
+	sessionCookie   = "dnd_session"
+	sessionLifetime = 12 * time.Hour
+	csrfField       = "csrf_token"
+	csrfHeader      = "X-CSRF-Token"
+)
+
+// session is a signed-in browser. The CSRF token must accompany every form
+// post, and every API call that authenticates with the session cookie.
+type session struct {
+	Username  string
+	CSRFToken string
+	Expires   time.Time
+}
+
+// sessionStore keeps sessions in memory; restarting the server signs
+// everyone out.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session
+}
+
+var sessions = &sessionStore{sessions: map[string]session{}}
+
+func (s *sessionStore) create(username string) (string, session, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", session{}, err
+	}
+	csrfToken, err := randomToken()
+	if err != nil {
+		return "", session{}, err
+	}
+	sess := session{Username: username, CSRFToken: csrfToken, Expires: time.Now().Add(sessionLifetime)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, existing := range s.sessions {
+		if time.Now().After(existing.Expires) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[token] = sess
+	return token, sess, nil
+}
+
+func (s *sessionStore) get(token string) (session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[token]
+	if !ok || time.Now().After(sess.Expires) {
+		delete(s.sessions, token)
+		return session{}, false
+	}
+	return sess, true
+}
+
+func (s *sessionStore) delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, token)
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	sessionKey
+)
+
+// currentUser returns the account that made the request. authenticate
+// guarantees there is one for every handler behind it.
+func currentUser(r *http.Request) models.User {
+	user, _ := r.Context().Value(userKey).(models.User)
+	return user
+}
+
+// csrfToken returns the token forms must post back, or "" for requests that
+// did not come from a browser session.
+func csrfToken(r *http.Request) string {
+	sess, _ := r.Context().Value(sessionKey).(session)
+	return sess.CSRFToken
+}
+
+func isPublicPath(path string) bool {
+	return path == "/login" || path == "/api/openapi.json" || strings.HasPrefix(path, "/static/")
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func tokensMatch(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// authenticate requires a signed-in user for everything except the login
+// page, static files and the API description. Browsers use the session
+// cookie; API clients may send HTTP basic credentials instead. Unsafe
+// requests made with the cookie must carry the session's CSRF token.
+func authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		isAPI := strings.HasPrefix(r.URL.Path, "/api/")
+
+		if username, password, ok := r.BasicAuth(); ok && isAPI {
+			user, err := commands.AuthenticateUser(username, password)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Basic realm="dnd-character-sheet"`)
+				writeAPIError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+			return
+		}
+
+		var sess session
+		var user models.User
+		cookie, err := r.Cookie(sessionCookie)
+		ok := err == nil
+		if ok {
+			sess, ok = sessions.get(cookie.Value)
+		}
+		if ok {
+			user, err = storage.GetUser(sess.Username)
+			if err != nil {
+				sessions.delete(cookie.Value)
+				ok = false
+			}
+		}
+		if !ok {
+			if isAPI {
+				w.Header().Set("WWW-Authenticate", `Basic realm="dnd-character-sheet"`)
+				writeAPIError(w, http.StatusUnauthorized, "sign in or send basic credentials")
+				return
+			}
+			http.Redirect(w, r, "/login?next="+r.URL.RequestURI(), http.StatusSeeOther)
+			return
+		}
+
+		if !isSafeMethod(r.Method) {
+			sent := r.Header.Get(csrfHeader)
+			if !isAPI {
+				sent = r.PostFormValue(csrfField)
+			}
+			if !tokensMatch(sent, sess.CSRFToken) {
+				if isAPI {
+					writeAPIError(w, http.StatusForbidden, "missing or invalid "+csrfHeader+" header")
+				} else {
+					http.Error(w, "Invalid or expired form, reload the page and try again", http.StatusForbidden)
+				}
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), userKey, user)
+		ctx = context.WithValue(ctx, sessionKey, sess)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type loginPage struct {
+	Next  string
+	Error string
+}
+
+// safeRedirect only allows paths on this server as the page to return to
+// after signing in.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		page := loginPage{Next: safeRedirect(r.URL.Query().Get("next"))}
+		if err := templates.ExecuteTemplate(w, "login.html", page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	next := safeRedirect(r.FormValue("next"))
+
+	user, err := commands.AuthenticateUser(r.FormValue("username"), r.FormValue("password"))
+	if err != nil {
+		status := http.StatusUnauthorized
+		if !errors.Is(err, commands.ErrInvalidCredentials) {
+			log.Println("Error signing in:", err)
+			status = http.StatusInternalServerError
+		}
+		w.WriteHeader(status)
+		if err := templates.ExecuteTemplate(w, "login.html", loginPage{Next: next, Error: err.Error()}); err != nil {
+			log.Println("Error rendering login page:", err)
+		}
+		return
+	}
+
+	token, sess, err := sessions.create(user.Username)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  sess.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		sessions.delete(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
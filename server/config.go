@@ -0,0 +1,30 @@
+package server
+
+import (
+	"io/fs"
+	"os"
+
+	"dnd-character-sheet/assets"
+)
+
+// Config holds the server settings. Empty template and static directories
+// mean the files embedded in the binary are used.
+type Config struct {
+	Addr        string
+	TemplateDir string
+	StaticDir   string
+}
+
+func (c Config) templateFS() (fs.FS, error) {
+	if c.TemplateDir != "" {
+		return os.DirFS(c.TemplateDir), nil
+	}
+	return fs.Sub(assets.Templates, "templates")
+}
+
+func (c Config) staticFS() (fs.FS, error) {
+	if c.StaticDir != "" {
+		return os.DirFS(c.StaticDir), nil
+	}
+	return fs.Sub(assets.Static, "static")
+}
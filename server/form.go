@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"dnd-character-sheet/models"
+)
+
+var abilityNames = []string{"Strength", "Dexterity", "Constitution", "Intelligence", "Wisdom", "Charisma"}
+
+var skillNames = []string{
+	"Acrobatics", "Animal Handling", "Arcana", "Athletics",
+	"Deception", "History", "Insight", "Intimidation",
+	"Investigation", "Medicine", "Nature", "Perception",
+	"Performance", "Persuasion", "Religion", "Sleight of Hand",
+	"Stealth", "Survival",
+}
+
+// sheetPage is the data passed to charactersheet.html. Errors maps form
+// input names to a validation message shown next to that input. Stored is
+// set for characters that have been saved, which can be managed from the
+// panel below the sheet; ActionError reports a failed action from that panel.
+type sheetPage struct {
+	models.Character
+	Errors      map[string]string
+	Stored      bool
+	ActionError string
+	User        models.User
+	CSRFToken   string
+}
+
+func newSheetPage(r *http.Request, character models.Character, stored bool) sheetPage {
+	return sheetPage{Character: character, Stored: stored, User: currentUser(r), CSRFToken: csrfToken(r)}
+}
+
+func (p sheetPage) HasSkillProficiency(skill string) bool {
+	for _, s := range p.SkillProficiencies {
+		if strings.EqualFold(s, skill) {
+			return true
+		}
+	}
+	return false
+}
+
+// sheetForm reads and validates the values posted by charactersheet.html.
+type sheetForm struct {
+	r      *http.Request
+	errors map[string]string
+}
+
+func newSheetForm(r *http.Request) *sheetForm {
+	return &sheetForm{r: r, errors: map[string]string{}}
+}
+
+func (f *sheetForm) text(name string) string {
+	return strings.TrimSpace(f.r.FormValue(name))
+}
+
+func (f *sheetForm) textarea(name string) string {
+	return strings.TrimRight(strings.ReplaceAll(f.r.FormValue(name), "\r\n", "\n"), "\n ")
+}
+
+func (f *sheetForm) checked(name string) bool {
+	return f.r.FormValue(name) == "on"
+}
+
+// integer parses a whole number between min and max. A blank input yields
+// fallback; anything else that isn't a valid number is reported as an error.
+func (f *sheetForm) integer(name, label string, fallback, min, max int) int {
+	raw := f.text(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		f.errors[name] = fmt.Sprintf("%s must be a whole number", label)
+		return fallback
+	}
+	if value < min || value > max {
+		f.errors[name] = fmt.Sprintf("%s must be between %d and %d", label, min, max)
+		return fallback
+	}
+	return value
+}
+
+func (f *sheetForm) count(names ...string) int {
+	total := 0
+	for _, name := range names {
+		if f.checked(name) {
+			total++
+		}
+	}
+	return total
+}
+
+// apply copies every editable input on the sheet onto character. The score
+// inputs hold base scores; racial bonuses are added on top, so saving the same
+// form twice gives the same totals. Derived values such as modifiers, skills
+// and saves are recalculated by the caller.
+func (f *sheetForm) apply(character *models.Character) {
+	character.Name = f.text("charname")
+	if character.Name == "" {
+		f.errors["charname"] = "Character name is required"
+	}
+	character.PlayerName = f.text("playername")
+	character.Race = strings.ToLower(f.text("race"))
+	character.Class = strings.ToLower(f.text("classlevel"))
+	character.Background = f.text("background")
+	character.Alignment = f.text("alignment")
+	character.Level = f.integer("level", "Level", 1, 1, 20)
+	character.ExperiencePoints = f.integer("experiencepoints", "Experience points", 0, 0, 355000)
+	character.Inspiration = f.checked("inspiration")
+
+	for _, ability := range abilityNames {
+		character.BaseAbilities.Set(ability, f.integer(ability+"score", ability, 10, 1, 30))
+	}
+	character.RacialBonuses = models.RacialAbilityBonuses(character.Race)
+	character.CalculateAbilityScores()
+
+	character.SkillProficiencies = nil
+	for _, skill := range skillNames {
+		if f.checked(skill + "-prof") {
+			character.SkillProficiencies = append(character.SkillProficiencies, skill)
+		}
+	}
+	if len(character.SkillProficiencies) == 0 {
+		character.SkillProficiencies = append([]string{}, models.ClassSkills[strings.ToLower(character.Class)]...)
+	}
+
+	character.SavingThrowProficiencies = nil
+	for _, ability := range abilityNames {
+		if f.checked(ability + "-save-prof") {
+			character.SavingThrowProficiencies = append(character.SavingThrowProficiencies, ability)
+		}
+	}
+	if len(character.SavingThrowProficiencies) == 0 {
+		character.SavingThrowProficiencies = models.GetSavingThrowProficiencies(character.Class)
+	}
+
+	character.Speed = f.integer("speed", "Speed", 30, 0, 200)
+	character.MaxHitPoints = f.integer("maxhp", "Hit point maximum", 0, 0, 999)
+	character.CurrentHitPoints = f.integer("currenthp", "Current hit points", character.MaxHitPoints, -999, 999)
+	character.TemporaryHitPoints = f.integer("temphp", "Temporary hit points", 0, 0, 999)
+	if character.CurrentHitPoints > character.MaxHitPoints && f.errors["currenthp"] == "" && f.errors["maxhp"] == "" {
+		f.errors["currenthp"] = "Current hit points cannot exceed the maximum"
+	}
+	character.HitDiceTotal = f.text("totalhd")
+	character.HitDiceRemaining = f.text("remaininghd")
+	character.DeathSaveSuccesses = f.count("deathsuccess1", "deathsuccess2", "deathsuccess3")
+	character.DeathSaveFailures = f.count("deathfail1", "deathfail2", "deathfail3")
+
+	character.CopperPieces = f.integer("cp", "Copper pieces", 0, 0, 1000000)
+	character.SilverPieces = f.integer("sp", "Silver pieces", 0, 0, 1000000)
+	character.ElectrumPieces = f.integer("ep", "Electrum pieces", 0, 0, 1000000)
+	character.GoldPieces = f.integer("gp", "Gold pieces", 0, 0, 1000000)
+	character.PlatinumPieces = f.integer("pp", "Platinum pieces", 0, 0, 1000000)
+	character.EquipmentText = f.textarea("equipment_text")
+
+	character.OtherProficiencies = f.textarea("otherprofs")
+	character.SpellNotes = f.textarea("spell_notes")
+	character.Personality = f.textarea("personality")
+	character.Ideals = f.textarea("ideals")
+	character.Bonds = f.textarea("bonds")
+	character.Flaws = f.textarea("flaws")
+	character.Features = f.textarea("features")
+}
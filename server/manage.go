@@ -0,0 +1,251 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"dnd-character-sheet/commands"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+// spellbookEntry is one row of the spellbook panel.
+type spellbookEntry struct {
+	Name       string
+	Level      int
+	Known      bool
+	Prepared   bool
+	CanLearn   bool
+	CanPrepare bool
+	// CopyCost is the gold it takes a wizard to copy the spell into the
+	// spellbook; zero when it can't.
+	CopyCost int
+}
+
+// WeaponOptions lists the weapons of the equipment catalog by name.
+func (p sheetPage) WeaponOptions() []string {
+	names := map[string]bool{}
+	for _, weapon := range commands.Weapons {
+		names[weapon.Name] = true
+	}
+	return sortedSet(names)
+}
+
+func (p sheetPage) ArmorOptions() []string {
+	names := map[string]bool{}
+	for _, armor := range commands.Armors {
+		names[armor.Name] = true
+	}
+	return sortedSet(names)
+}
+
+func (p sheetPage) ShieldOptions() []string {
+	names := map[string]bool{}
+	for _, shield := range commands.Shields {
+		names[shield.Name] = true
+	}
+	return sortedSet(names)
+}
+
+// Spellbook lists the spells of the character's class with what the
+// character can do with each: learn it, or prepare it when it has slots of
+// the spell's level. Wizards learn spells by writing them into their
+// spellbook, or by copying them, and prepare only spells in the book.
+func (p sheetPage) Spellbook() []spellbookEntry {
+	known := map[string]models.Spell{}
+	for _, spell := range p.Spells {
+		known[spell.Name] = spell
+	}
+
+	spellbook := commands.SpellLimitsFor(p.Character).Spellbook > 0
+	highestSlot := 0
+	for level, slots := range p.SpellSlots {
+		if level > highestSlot && slots > 0 {
+			highestSlot = level
+		}
+	}
+
+	classSpells := commands.FindSpellsForClass(p.Class)
+	sort.SliceStable(classSpells, func(i, j int) bool {
+		if classSpells[i].Level != classSpells[j].Level {
+			return classSpells[i].Level < classSpells[j].Level
+		}
+		return classSpells[i].Name < classSpells[j].Name
+	})
+
+	entries := make([]spellbookEntry, 0, len(classSpells))
+	for _, spell := range classSpells {
+		entry := spellbookEntry{Name: spell.Name, Level: spell.Level}
+		if own, ok := known[spell.Name]; ok {
+			entry.Known = true
+			entry.Prepared = own.Prepared
+		}
+		switch {
+		case spellbook && spell.Level > 0:
+			entry.CanPrepare = entry.Known && !entry.Prepared
+			if !entry.Known && spell.Level <= highestSlot {
+				entry.CanLearn = true
+				entry.CopyCost = commands.CopySpellGoldPerLevel * spell.Level
+			}
+		case p.CanPrepareSpells && spell.Level > 0:
+			entry.CanPrepare = !entry.Prepared && spell.Level <= highestSlot
+		default:
+			entry.CanLearn = !entry.Known
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// spellCard is one of the character's spells with its full description.
+type spellCard struct {
+	models.Spell
+	Paragraphs []string
+}
+
+// SpellCards lists the character's spells that have a full spell card, by
+// level and name.
+func (p sheetPage) SpellCards() []spellCard {
+	var cards []spellCard
+	for _, spell := range p.Spells {
+		if spell.HasDetails() {
+			cards = append(cards, spellCard{Spell: spell, Paragraphs: strings.Split(spell.Description, "\n\n")})
+		}
+	}
+	sort.SliceStable(cards, func(i, j int) bool {
+		if cards[i].Level != cards[j].Level {
+			return cards[i].Level < cards[j].Level
+		}
+		return cards[i].Name < cards[j].Name
+	})
+	return cards
+}
+
+func sortedSet(set map[string]bool) []string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// runSheetAction runs a command for the character named in the posted form.
+// On success the browser is sent back to the sheet; on failure the sheet is
+// shown again with the command's error.
+func runSheetAction(w http.ResponseWriter, r *http.Request, action func(name string) error) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	character, err := storage.GetCharacterByName(name)
+	if errors.Is(err, storage.ErrCharacterNotFound) {
+		http.Error(w, "Character not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !currentUser(r).CanEdit(character) {
+		http.Error(w, "This character belongs to another player", http.StatusForbidden)
+		return
+	}
+
+	if err := action(name); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		page := newSheetPage(r, character, true)
+		page.ActionError = err.Error()
+		if err := templates.ExecuteTemplate(w, "charactersheet.html", page); err != nil {
+			log.Println("Error rendering character sheet:", err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/character?id="+url.QueryEscape(name), http.StatusSeeOther)
+}
+
+func deleteCharacterHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	character, err := storage.GetCharacterByName(r.FormValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !currentUser(r).CanEdit(character) {
+		http.Error(w, "This character belongs to another player", http.StatusForbidden)
+		return
+	}
+
+	if err := commands.DeleteCharacter(character.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func equipHandler(w http.ResponseWriter, r *http.Request) {
+	runSheetAction(w, r, func(name string) error {
+		switch {
+		case r.FormValue("weapon") != "":
+			weapon, ok := commands.Weapons[strings.ToLower(r.FormValue("weapon"))]
+			if !ok {
+				return errors.New("unknown weapon " + r.FormValue("weapon"))
+			}
+			_, err := commands.AddWeaponToSlot(name, weapon, r.FormValue("slot"))
+			return err
+		case r.FormValue("armor") != "":
+			armor, ok := commands.Armors[strings.ToLower(r.FormValue("armor"))]
+			if !ok {
+				return errors.New("unknown armor " + r.FormValue("armor"))
+			}
+			_, err := commands.AddArmor(name, armor.Name)
+			return err
+		case r.FormValue("shield") != "":
+			shield, ok := commands.Shields[strings.ToLower(r.FormValue("shield"))]
+			if !ok {
+				return errors.New("unknown shield " + r.FormValue("shield"))
+			}
+			return commands.AddShield(name, shield.Name)
+		}
+		return errors.New("choose a weapon, armor or shield to equip")
+	})
+}
+
+func learnSpellHandler(w http.ResponseWriter, r *http.Request) {
+	runSheetAction(w, r, func(name string) error {
+		_, err := commands.LearnSpell(name, r.FormValue("spell"))
+		return err
+	})
+}
+
+func prepareSpellHandler(w http.ResponseWriter, r *http.Request) {
+	runSheetAction(w, r, func(name string) error {
+		_, err := commands.PrepareSpell(name, []string{r.FormValue("spell")}, false)
+		return err
+	})
+}
+
+func copySpellHandler(w http.ResponseWriter, r *http.Request) {
+	runSheetAction(w, r, func(name string) error {
+		_, err := commands.CopySpell(name, r.FormValue("spell"))
+		return err
+	})
+}
+
+func unprepareSpellHandler(w http.ResponseWriter, r *http.Request) {
+	runSheetAction(w, r, func(name string) error {
+		_, err := commands.UnprepareSpell(name, []string{r.FormValue("spell")})
+		return err
+	})
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"dnd-character-sheet/commands"
+	"dnd-character-sheet/models"
+	"dnd-character-sheet/storage"
+)
+
+// shutdownTimeout bounds how long Run waits for in-flight requests.
+const shutdownTimeout = 10 * time.Second
+
+var templates *template.Template
+
+// listPage is the data passed to characterList.html.
+type listPage struct {
+	Characters map[string]models.Character
+	User       models.User
+	CSRFToken  string
+}
+
+func listHandler(w http.ResponseWriter, r *http.Request) {
+	allCharacters, err := storage.LoadCharacters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user := currentUser(r)
+	characters := map[string]models.Character{}
+	for name, character := range allCharacters {
+		if user.CanEdit(character) {
+			characters[name] = character
+		}
+	}
+
+	page := listPage{Characters: characters, User: user, CSRFToken: csrfToken(r)}
+	err = templates.ExecuteTemplate(w, "characterList.html", page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func characterHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		characterID := r.URL.Query().Get("id")
+		page := newSheetPage(r, models.Character{PlayerName: currentUser(r).Username}, false)
+		if characterID != "" {
+			foundCharacter, err := storage.GetCharacterByName(characterID)
+			if err == nil {
+				if !currentUser(r).CanEdit(foundCharacter) {
+					http.Error(w, "This character belongs to another player", http.StatusForbidden)
+					return
+				}
+				page.Character = foundCharacter
+				page.Stored = true
+			}
+		}
+
+		err := templates.ExecuteTemplate(w, "charactersheet.html", page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+
+	case http.MethodPost:
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user := currentUser(r)
+		form := newSheetForm(r)
+		character, err := storage.GetCharacterByName(form.text("charname"))
+		stored := err == nil
+		if stored && !user.CanEdit(character) {
+			http.Error(w, "This character belongs to another player", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			character = models.Character{}
+		}
+		form.apply(&character)
+		if !user.IsDM() {
+			character.PlayerName = user.Username
+		}
+
+		character.ProficiencyBonus = models.CalculateProfBonus(character.Level)
+		character.CalculateAbilityModifiers()
+		character.CalculateAllSkills()
+		character.CalculateSavingThrows()
+		character.CalculateCombatStats()
+		commands.SetupSpellcasting(&character)
+
+		if len(form.errors) > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			page := newSheetPage(r, character, stored)
+			page.Errors = form.errors
+			err := templates.ExecuteTemplate(w, "charactersheet.html", page)
+			if err != nil {
+				log.Println("Error rendering character sheet:", err)
+			}
+			return
+		}
+
+		// Equipment and spells are not on the form: existing characters keep
+		// theirs and new ones start with as many class cantrips as they can know.
+		if stored {
+			err = commands.UpdateCharacter(character.Name, character)
+		} else {
+			err = commands.AddCharacter(character)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			page := newSheetPage(r, character, stored)
+			page.ActionError = err.Error()
+			err := templates.ExecuteTemplate(w, "charactersheet.html", page)
+			if err != nil {
+				log.Println("Error rendering character sheet:", err)
+			}
+			return
+		}
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// NewHandler returns the web UI and JSON API. The spell and equipment
+// catalogs must already be loaded.
+func NewHandler(cfg Config) (http.Handler, error) {
+	templateFS, err := cfg.templateFS()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open templates: %w", err)
+	}
+	templates, err = template.ParseFS(templateFS, "*.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	staticFS, err := cfg.staticFS()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open static files: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	registerAPIRoutes(mux)
+	mux.HandleFunc("/login", loginHandler)
+	mux.HandleFunc("POST /logout", logoutHandler)
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
+	mux.HandleFunc("/", listHandler)
+	mux.HandleFunc("/character", characterHandler)
+	mux.HandleFunc("POST /character/delete", deleteCharacterHandler)
+	mux.HandleFunc("POST /character/equip", equipHandler)
+	mux.HandleFunc("POST /character/learn-spell", learnSpellHandler)
+	mux.HandleFunc("POST /character/prepare-spell", prepareSpellHandler)
+	mux.HandleFunc("POST /character/unprepare-spell", unprepareSpellHandler)
+	mux.HandleFunc("POST /character/copy-spell", copySpellHandler)
+	return authenticate(serializeWrites(mux)), nil
+}
+
+// writeMu lets one request change characters at a time, so a request that
+// reads a character and saves it changed never overwrites a change made in
+// between by another request.
+var writeMu sync.Mutex
+
+func serializeWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeMu.Lock()
+			defer writeMu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Run serves until ctx is cancelled, then lets in-flight requests finish
+// before returning.
+func Run(ctx context.Context, cfg Config) error {
+	handler, err := NewHandler(cfg)
+	if err != nil {
+		return err
+	}
+
+	if users, err := storage.LoadUsers(); err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	} else if len(users) == 0 {
+		log.Println("No user accounts yet; add one with: user-add -username NAME -role dm")
+	}
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: handler}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	log.Printf("Server started at %s\n", cfg.Addr)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"dnd-character-sheet/models"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldChange describes a single changed field between two versions of a
+// character. Field is a dotted JSON path such as "abilities.strength".
+type FieldChange struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old,omitempty"`
+	New   json.RawMessage `json:"new,omitempty"`
+}
+
+func (f FieldChange) String() string {
+	if added, removed, ok := diffArrays(f.Old, f.New); ok {
+		var parts []string
+		for _, item := range added {
+			parts = append(parts, "+"+string(item))
+		}
+		for _, item := range removed {
+			parts = append(parts, "-"+string(item))
+		}
+		return fmt.Sprintf("%s: %s", f.Field, strings.Join(parts, " "))
+	}
+
+	oldValue, newValue := string(f.Old), string(f.New)
+	if oldValue == "" {
+		oldValue = "(none)"
+	}
+	if newValue == "" {
+		newValue = "(none)"
+	}
+	return fmt.Sprintf("%s: %s -> %s", f.Field, oldValue, newValue)
+}
+
+// diffArrays reports which elements were added to and removed from a JSON
+// array. ok is false when either side is not an array.
+func diffArrays(oldValue, newValue json.RawMessage) (added, removed []json.RawMessage, ok bool) {
+	var oldItems, newItems []json.RawMessage
+	if len(oldValue) > 0 && json.Unmarshal(oldValue, &oldItems) != nil {
+		return nil, nil, false
+	}
+	if len(newValue) > 0 && json.Unmarshal(newValue, &newItems) != nil {
+		return nil, nil, false
+	}
+	if oldItems == nil && newItems == nil {
+		return nil, nil, false
+	}
+
+	count := map[string]int{}
+	for _, item := range oldItems {
+		count[string(item)]++
+	}
+	for _, item := range newItems {
+		if count[string(item)] > 0 {
+			count[string(item)]--
+			continue
+		}
+		added = append(added, item)
+	}
+	for _, item := range oldItems {
+		if count[string(item)] > 0 {
+			count[string(item)]--
+			removed = append(removed, item)
+		}
+	}
+	return added, removed, true
+}
+
+// DiffCharacters returns the field-level differences between two characters,
+// sorted by field path.
+func DiffCharacters(before, after models.Character) ([]FieldChange, error) {
+	beforeFields, err := flattenCharacter(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flattenCharacter(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	for field, oldValue := range beforeFields {
+		newValue, ok := afterFields[field]
+		if !ok {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue})
+			continue
+		}
+		if string(oldValue) != string(newValue) {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	for field, newValue := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func flattenCharacter(character models.Character) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(character)
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	for key, value := range root {
+		flattenValue(key, value, fields)
+	}
+	return fields, nil
+}
+
+func flattenValue(prefix string, value json.RawMessage, fields map[string]json.RawMessage) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(value, &object); err == nil && object != nil {
+		for key, nested := range object {
+			flattenValue(prefix+"."+key, nested, fields)
+		}
+		return
+	}
+	fields[prefix] = value
+}
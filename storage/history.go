@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"bufio"
+	"dnd-character-sheet/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var HistoryFilePath = "history.jsonl"
+
+// HistoryEntry is one line of the append-only change log. Entries belong to
+// the character with CharacterID, so they follow renames; Character is the
+// name at the time. Undo entries list the sequence numbers they revert in
+// Reverts. Deleting a character appends an entry with Deleted set, which ends
+// its history, so a later character that reuses its name or ID starts with an
+// empty one.
+type HistoryEntry struct {
+	Sequence    int           `json:"seq"`
+	CharacterID int           `json:"character_id,omitempty"`
+	Character   string        `json:"character"`
+	Command     string        `json:"command"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Changes     []FieldChange `json:"changes"`
+	Reverts     []int         `json:"reverts,omitempty"`
+	Deleted     bool          `json:"deleted,omitempty"`
+}
+
+// RecordChange appends the difference between before and after to the change
+// log. Nothing is written when the two are identical.
+func RecordChange(command string, before, after models.Character) error {
+	changes, err := DiffCharacters(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	_, err = appendHistory(HistoryEntry{
+		CharacterID: after.ID,
+		Character:   after.Name,
+		Command:     command,
+		Changes:     changes,
+	})
+	return err
+}
+
+// belongsTo reports whether the entry is part of the character's history.
+// Entries written before IDs were recorded are matched by name.
+func (e HistoryEntry) belongsTo(character models.Character) bool {
+	if e.CharacterID != 0 {
+		return e.CharacterID == character.ID
+	}
+	return e.Character == character.Name
+}
+
+// LoadHistory returns the change log of a single character, oldest first.
+func LoadHistory(characterName string) ([]HistoryEntry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	allCharacters, err := loadStoredCharacters()
+	if err != nil {
+		return nil, err
+	}
+	character, exists := allCharacters[characterName]
+	if !exists {
+		return nil, ErrCharacterNotFound
+	}
+	return characterHistory(character)
+}
+
+// characterHistory returns the entries of character since it was last
+// deleted. The caller holds mu.
+func characterHistory(character models.Character) ([]HistoryEntry, error) {
+	entries, err := loadAllHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	var history []HistoryEntry
+	for _, entry := range entries {
+		if !entry.belongsTo(character) {
+			continue
+		}
+		if entry.Deleted {
+			history = nil
+			continue
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// UndoChanges reverts the last steps changes of a character that have not
+// already been undone, saves the result and logs the undo itself.
+func UndoChanges(characterName string, steps int) (models.Character, []HistoryEntry, error) {
+	if steps < 1 {
+		return models.Character{}, nil, errors.New("steps must be at least 1")
+	}
+
+	// The character is loaded, reverted and replaced in one critical section,
+	// so no other change lands in between.
+	mu.Lock()
+	defer mu.Unlock()
+
+	allCharacters, err := loadStoredCharacters()
+	if err != nil {
+		return models.Character{}, nil, err
+	}
+	stored, exists := allCharacters[characterName]
+	if !exists {
+		return models.Character{}, nil, ErrCharacterNotFound
+	}
+	character, _, err := MigrateCharacter(stored)
+	if err != nil {
+		return models.Character{}, nil, err
+	}
+
+	history, err := characterHistory(character)
+	if err != nil {
+		return models.Character{}, nil, err
+	}
+
+	reverted := map[int]bool{}
+	for _, entry := range history {
+		for _, seq := range entry.Reverts {
+			reverted[seq] = true
+		}
+	}
+
+	var undone []HistoryEntry
+	for i := len(history) - 1; i >= 0 && len(undone) < steps; i-- {
+		entry := history[i]
+		if len(entry.Reverts) > 0 || reverted[entry.Sequence] {
+			continue
+		}
+		undone = append(undone, entry)
+	}
+	if len(undone) == 0 {
+		return models.Character{}, nil, fmt.Errorf("nothing to undo for character '%s'", characterName)
+	}
+
+	restored := character
+	var reverts []int
+	for _, entry := range undone {
+		restored, err = RevertChanges(restored, entry.Changes)
+		if err != nil {
+			return models.Character{}, nil, err
+		}
+		reverts = append(reverts, entry.Sequence)
+	}
+
+	// Undoing a rename restores the old name, so the character is replaced
+	// rather than saved under the name it has now.
+	if err := replaceCharacter(allCharacters, character.Name, restored); err != nil {
+		return models.Character{}, nil, err
+	}
+
+	changes, err := DiffCharacters(character, restored)
+	if err != nil {
+		return models.Character{}, nil, err
+	}
+	if _, err := appendHistory(HistoryEntry{
+		CharacterID: restored.ID,
+		Character:   restored.Name,
+		Command:     "undo",
+		Changes:     changes,
+		Reverts:     reverts,
+	}); err != nil {
+		return models.Character{}, nil, err
+	}
+
+	return restored, undone, nil
+}
+
+// RevertChanges sets every field listed in changes back to its old value.
+func RevertChanges(character models.Character, changes []FieldChange) (models.Character, error) {
+	data, err := json.Marshal(character)
+	if err != nil {
+		return character, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return character, err
+	}
+
+	ordered := append([]FieldChange(nil), changes...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		iDelete, jDelete := len(ordered[i].Old) == 0, len(ordered[j].Old) == 0
+		if iDelete != jDelete {
+			return iDelete
+		}
+		return ordered[i].Field < ordered[j].Field
+	})
+
+	for _, change := range ordered {
+		path := strings.Split(change.Field, ".")
+		if len(change.Old) == 0 {
+			deletePath(document, path)
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(change.Old, &value); err != nil {
+			return character, fmt.Errorf("invalid value for %s: %w", change.Field, err)
+		}
+		setPath(document, path, value)
+	}
+
+	data, err = json.Marshal(document)
+	if err != nil {
+		return character, err
+	}
+	var restored models.Character
+	if err := json.Unmarshal(data, &restored); err != nil {
+		return character, err
+	}
+	return restored, nil
+}
+
+func setPath(document map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := document[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			document[key] = next
+		}
+		document = next
+	}
+	document[path[len(path)-1]] = value
+}
+
+// deletePath removes the value at path and prunes objects left empty, so a
+// reverted "equipment.armor.name" does not leave an empty armor behind.
+func deletePath(document map[string]interface{}, path []string) {
+	if len(path) == 1 {
+		delete(document, path[0])
+		return
+	}
+	next, ok := document[path[0]].(map[string]interface{})
+	if !ok {
+		return
+	}
+	deletePath(next, path[1:])
+	if len(next) == 0 {
+		delete(document, path[0])
+	}
+}
+
+// appendHistory numbers entry after the last entry of its character and
+// appends it to the log. The caller holds mu.
+func appendHistory(entry HistoryEntry) (HistoryEntry, error) {
+	entries, err := loadAllHistory()
+	if err != nil {
+		return entry, err
+	}
+	owner := models.Character{ID: entry.CharacterID, Name: entry.Character}
+	for _, existing := range entries {
+		if existing.belongsTo(owner) && existing.Sequence > entry.Sequence {
+			entry.Sequence = existing.Sequence
+		}
+	}
+	entry.Sequence++
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+
+	file, err := os.OpenFile(HistoryFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return entry, err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+func loadAllHistory() ([]HistoryEntry, error) {
+	file, err := os.Open(HistoryFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("corrupt history entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
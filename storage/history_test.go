@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bytes"
+	"dnd-character-sheet/models"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+// saveChange saves after in place of before and records the change, as the
+// commands do.
+func saveChange(t *testing.T, command string, before, after models.Character) {
+	t.Helper()
+	if err := ReplaceCharacter(before.Name, after); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordChange(command, before, after); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// savedElf stores a current version of unversionedElf and returns it.
+func savedElf(t *testing.T) models.Character {
+	t.Helper()
+	useTempStorage(t)
+	character, _, err := MigrateCharacter(unversionedElf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveCharacter(character); err != nil {
+		t.Fatal(err)
+	}
+	return character
+}
+
+func TestRecordChangeSkipsNoChange(t *testing.T) {
+	character := savedElf(t)
+	if err := RecordChange("noop", character, character.Clone()); err != nil {
+		t.Fatal(err)
+	}
+	history, err := LoadHistory(character.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("history %+v, want nothing for an unchanged character", history)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	original := savedElf(t)
+	aligned := original.Clone()
+	aligned.Alignment = "chaotic good"
+	saveChange(t, "set alignment", original, aligned)
+	leveled := aligned.Clone()
+	leveled.Level = 2
+	saveChange(t, "level up", aligned, leveled)
+
+	restored, undone, err := UndoChanges(original.Name, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 1 || undone[0].Command != "level up" {
+		t.Errorf("undid %+v, want the level up", undone)
+	}
+	if restored.Level != 1 || restored.Alignment != "chaotic good" {
+		t.Errorf("after one undo: level %d, alignment %q", restored.Level, restored.Alignment)
+	}
+
+	// The level up is undone already, so the next undo reverts the alignment.
+	restored, undone, err = UndoChanges(original.Name, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 1 || undone[0].Command != "set alignment" || restored.Alignment != original.Alignment {
+		t.Errorf("second undo reverted %+v, alignment %q", undone, restored.Alignment)
+	}
+	saved, err := GetCharacterByName(original.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Alignment != original.Alignment || saved.Level != 1 {
+		t.Errorf("saved alignment %q, level %d", saved.Alignment, saved.Level)
+	}
+
+	if _, _, err := UndoChanges(original.Name, 1); err == nil {
+		t.Error("undo with nothing left to undo succeeded")
+	}
+	history, err := LoadHistory(original.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 4 || len(history[2].Reverts) != 1 || history[2].Reverts[0] != history[1].Sequence {
+		t.Errorf("history %+v, want two changes and two undos", history)
+	}
+}
+
+func TestUndoSeveralSteps(t *testing.T) {
+	original := savedElf(t)
+	character := original
+	for level := 2; level <= 4; level++ {
+		next := character.Clone()
+		next.Level = level
+		saveChange(t, "level up", character, next)
+		character = next
+	}
+
+	restored, undone, err := UndoChanges(original.Name, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 2 || restored.Level != 2 {
+		t.Errorf("undid %d changes to level %d, want 2 to level 2", len(undone), restored.Level)
+	}
+	if _, _, err := UndoChanges(original.Name, 0); err == nil {
+		t.Error("undoing 0 steps succeeded")
+	}
+}
+
+func TestUndoRename(t *testing.T) {
+	original := savedElf(t)
+	renamed := original.Clone()
+	renamed.Name = "Elros"
+	saveChange(t, "rename", original, renamed)
+
+	history, err := LoadHistory("Elros")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].CharacterID != original.ID {
+		t.Errorf("history after rename %+v", history)
+	}
+
+	restored, _, err := UndoChanges("Elros", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Name != original.Name || restored.ID != original.ID {
+		t.Errorf("restored %q with ID %d", restored.Name, restored.ID)
+	}
+	characters, err := LoadCharacters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := characters["Elros"]; ok || len(characters) != 1 {
+		t.Errorf("characters after undoing the rename: %d, Elros kept: %v", len(characters), ok)
+	}
+}
+
+func TestDeleteClearsHistory(t *testing.T) {
+	original := savedElf(t)
+	aligned := original.Clone()
+	aligned.Alignment = "neutral"
+	saveChange(t, "set alignment", original, aligned)
+	before, err := os.ReadFile(HistoryFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DeleteCharacter(original.Name); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(HistoryFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(after, before) || len(after) == len(before) {
+		t.Error("deleting a character didn't append to the history file")
+	}
+	if _, err := LoadHistory(original.Name); !errors.Is(err, ErrCharacterNotFound) {
+		t.Errorf("history of a deleted character: %v", err)
+	}
+
+	// A new character with the same name starts without the old history.
+	if err := SaveCharacter(original); err != nil {
+		t.Fatal(err)
+	}
+	history, err := LoadHistory(original.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("recreated character has history %+v", history)
+	}
+}
+
+func TestRevertChanges(t *testing.T) {
+	before := savedElf(t)
+	after := before.Clone()
+	after.Alignment = "lawful evil"
+	after.SkillProficiencies = append(after.SkillProficiencies, "Arcana")
+	after.Abilities.Strength = 18
+	changes, err := DiffCharacters(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := RevertChanges(after, changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Alignment != before.Alignment || len(reverted.SkillProficiencies) != len(before.SkillProficiencies) || reverted.Abilities != before.Abilities {
+		t.Errorf("reverted %q, %v, %+v", reverted.Alignment, reverted.SkillProficiencies, reverted.Abilities)
+	}
+}
+
+func TestConcurrentChangesGetTheirOwnSequence(t *testing.T) {
+	original := savedElf(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			changed := original.Clone()
+			changed.Alignment = fmt.Sprintf("alignment %d", i)
+			if err := RecordChange("set alignment", original, changed); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	history, err := LoadHistory(original.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for _, entry := range history {
+		if seen[entry.Sequence] {
+			t.Errorf("sequence %d used twice", entry.Sequence)
+		}
+		seen[entry.Sequence] = true
+	}
+	if len(history) != 20 {
+		t.Errorf("%d entries, want 20", len(history))
+	}
+}
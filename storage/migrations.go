@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"dnd-character-sheet/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Migration upgrades a character stored at Version-1 to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(character *models.Character)
+}
+
+var migrations []Migration
+
+// RegisterMigration adds a migration to the registry. Versions must be unique
+// and are applied in ascending order.
+func RegisterMigration(migration Migration) {
+	for _, existing := range migrations {
+		if existing.Version == migration.Version {
+			panic(fmt.Sprintf("storage: duplicate migration for schema version %d", migration.Version))
+		}
+	}
+	migrations = append(migrations, migration)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+}
+
+// CurrentSchemaVersion is the schema version written by SaveCharacter and
+// SaveAllCharacters.
+func CurrentSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func init() {
+	RegisterMigration(Migration{
+		Version:     1,
+		Description: "normalize race/class casing and recompute derived stats",
+		Apply: func(character *models.Character) {
+			character.Race = strings.ToLower(strings.TrimSpace(character.Race))
+			character.Class = strings.ToLower(strings.TrimSpace(character.Class))
+			character.RecalculateDerivedStats()
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     2,
+		Description: "add class saving throw proficiencies and computed saves",
+		Apply: func(character *models.Character) {
+			if len(character.SavingThrowProficiencies) == 0 {
+				character.SavingThrowProficiencies = models.GetSavingThrowProficiencies(character.Class)
+			}
+			character.CalculateSavingThrows()
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     3,
+		Description: "apply proficiency levels to skills and add passive insight/investigation",
+		Apply: func(character *models.Character) {
+			character.CalculateAllSkills()
+			character.CalculateCombatStats()
+		},
+	})
+	RegisterMigration(Migration{
+		Version:     4,
+		Description: "split ability scores into base scores and racial bonuses",
+		Apply: func(character *models.Character) {
+			if character.BaseAbilities != (models.AbilityScores{}) {
+				return
+			}
+			// Scores were stored with racial bonuses already applied (web
+			// saves may have applied them more than once). Treat the stored
+			// total as authoritative and derive the base from it.
+			character.RacialBonuses = models.RacialAbilityBonuses(character.Race)
+			for _, name := range []string{"Strength", "Dexterity", "Constitution", "Intelligence", "Wisdom", "Charisma"} {
+				base := character.Abilities.Score(name) - character.RacialBonuses.Score(name) - character.OtherBonuses.Score(name)
+				if base < 1 {
+					base = 1
+				}
+				character.BaseAbilities.Set(name, base)
+			}
+			character.RecalculateDerivedStats()
+		},
+	})
+}
+
+// MigrationResult reports what migrating a single character changed.
+type MigrationResult struct {
+	Name        string
+	FromVersion int
+	ToVersion   int
+	Applied     []string
+	Changes     []FieldChange
+}
+
+// MigrateCharacter runs every registered migration newer than the character's
+// schema version and returns the upgraded copy together with a report.
+func MigrateCharacter(character models.Character) (models.Character, MigrationResult, error) {
+	result := MigrationResult{
+		Name:        character.Name,
+		FromVersion: character.SchemaVersion,
+		ToVersion:   character.SchemaVersion,
+	}
+
+	migrated := character.Clone()
+	for _, migration := range migrations {
+		if migration.Version <= migrated.SchemaVersion {
+			continue
+		}
+		migration.Apply(&migrated)
+		migrated.SchemaVersion = migration.Version
+		result.ToVersion = migration.Version
+		result.Applied = append(result.Applied, migration.Description)
+	}
+	if len(result.Applied) == 0 {
+		return character, result, nil
+	}
+
+	changes, err := DiffCharacters(character, migrated)
+	if err != nil {
+		return character, result, err
+	}
+	result.Changes = changes
+	return migrated, result, nil
+}
+
+// PlanMigrations reports what loading the characters file would change
+// without writing anything.
+func PlanMigrations() ([]MigrationResult, error) {
+	characters, err := loadCharactersFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []MigrationResult
+	for _, name := range sortedNames(characters) {
+		_, result, err := MigrateCharacter(characters[name])
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Applied) > 0 {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// MigrateAll upgrades every stored character and writes the file back.
+func MigrateAll() ([]MigrationResult, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	characters, err := loadCharactersFile()
+	if err != nil {
+		return nil, err
+	}
+
+	results, changed, err := migrateCharacters(characters)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := saveAllCharacters(characters); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func migrateCharacters(characters map[string]models.Character) ([]MigrationResult, bool, error) {
+	var results []MigrationResult
+	changed := false
+	for _, name := range sortedNames(characters) {
+		migrated, result, err := MigrateCharacter(characters[name])
+		if err != nil {
+			return nil, false, err
+		}
+		if len(result.Applied) == 0 {
+			continue
+		}
+		characters[name] = migrated
+		results = append(results, result)
+		changed = true
+	}
+	return results, changed, nil
+}
+
+func sortedNames(characters map[string]models.Character) []string {
+	names := make([]string, 0, len(characters))
+	for name := range characters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"dnd-character-sheet/models"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useTempStorage keeps characters and history in a fresh directory for the
+// rest of the test.
+func useTempStorage(t *testing.T) {
+	t.Helper()
+	characters, history, users := CharactersFilePath, HistoryFilePath, UsersFilePath
+	t.Cleanup(func() {
+		CharactersFilePath, HistoryFilePath, UsersFilePath = characters, history, users
+	})
+	Configure(filepath.Join(t.TempDir(), "characters.json"))
+}
+
+// unversionedElf is a character as it was stored before schema versions: the
+// elf's Dexterity bonus is already part of its scores and nothing is derived.
+func unversionedElf() models.Character {
+	return models.Character{
+		ID:        1,
+		Name:      "Elrond",
+		Race:      " Elf ",
+		Class:     "Wizard",
+		Level:     1,
+		Abilities: models.AbilityScores{Strength: 8, Dexterity: 16, Constitution: 12, Intelligence: 15, Wisdom: 10, Charisma: 1},
+	}
+}
+
+func TestMigrationsAreContiguous(t *testing.T) {
+	if CurrentSchemaVersion() != 4 {
+		t.Fatalf("schema version %d, want 4", CurrentSchemaVersion())
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d has version %d", i, migration.Version)
+		}
+		if migration.Description == "" || migration.Apply == nil {
+			t.Errorf("migration %d has no description or function", migration.Version)
+		}
+	}
+}
+
+func TestRegisterMigrationRejectsDuplicates(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering version 2 again didn't panic")
+		}
+	}()
+	RegisterMigration(Migration{Version: 2, Description: "again", Apply: func(*models.Character) {}})
+}
+
+// applyUpTo runs the registered migrations up to version on character.
+func applyUpTo(character models.Character, version int) models.Character {
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			migration.Apply(&character)
+		}
+	}
+	return character
+}
+
+func TestMigrationSteps(t *testing.T) {
+	v1 := applyUpTo(unversionedElf(), 1)
+	if v1.Race != "elf" || v1.Class != "wizard" {
+		t.Errorf("v1: race %q, class %q", v1.Race, v1.Class)
+	}
+	if v1.ProficiencyBonus != 2 || v1.DexterityMod != 3 || v1.Abilities.Dexterity != 16 {
+		t.Errorf("v1: proficiency %d, Dexterity %d (%+d)", v1.ProficiencyBonus, v1.Abilities.Dexterity, v1.DexterityMod)
+	}
+
+	v2 := applyUpTo(unversionedElf(), 2)
+	if len(v2.SavingThrowProficiencies) != 2 || !v2.HasSavingThrowProficiency("Intelligence") || !v2.HasSavingThrowProficiency("Wisdom") {
+		t.Errorf("v2: saving throw proficiencies %v", v2.SavingThrowProficiencies)
+	}
+	if v2.SavingThrows["Intelligence"] != 4 || v2.SavingThrows["Strength"] != -1 {
+		t.Errorf("v2: saving throws %v", v2.SavingThrows)
+	}
+	kept := unversionedElf()
+	kept.SavingThrowProficiencies = []string{"Strength"}
+	if kept = applyUpTo(kept, 2); len(kept.SavingThrowProficiencies) != 1 || kept.SavingThrows["Strength"] != 1 {
+		t.Errorf("v2 replaced existing proficiencies: %v, %v", kept.SavingThrowProficiencies, kept.SavingThrows)
+	}
+
+	v3 := applyUpTo(unversionedElf(), 3)
+	if len(v3.Skills) != len(models.SkillAbilities) || v3.Skills["Acrobatics"] != 3 {
+		t.Errorf("v3: skills %v", v3.Skills)
+	}
+	if v3.PassiveInsight != 10 || v3.PassiveInvestigation != 12 {
+		t.Errorf("v3: passive insight %d, investigation %d", v3.PassiveInsight, v3.PassiveInvestigation)
+	}
+
+	v4 := applyUpTo(unversionedElf(), 4)
+	if v4.RacialBonuses.Dexterity != 2 || v4.BaseAbilities.Dexterity != 14 || v4.Abilities.Dexterity != 16 {
+		t.Errorf("v4: Dexterity %d base + %d racial = %d", v4.BaseAbilities.Dexterity, v4.RacialBonuses.Dexterity, v4.Abilities.Dexterity)
+	}
+	if v4.BaseAbilities.Intelligence != 15 || v4.BaseAbilities.Charisma != 1 {
+		t.Errorf("v4: base scores %+v", v4.BaseAbilities)
+	}
+	human := unversionedElf()
+	human.Race = "human"
+	if human = applyUpTo(human, 4); human.BaseAbilities.Charisma != 1 || human.BaseAbilities.Strength != 7 {
+		t.Errorf("v4: human base scores %+v, want at least 1", human.BaseAbilities)
+	}
+}
+
+func TestMigrationKeepsBaseScores(t *testing.T) {
+	character := unversionedElf()
+	character.SchemaVersion = 3
+	character.BaseAbilities = models.AbilityScores{Strength: 10, Dexterity: 10, Constitution: 10, Intelligence: 10, Wisdom: 10, Charisma: 10}
+	migrated := applyUpTo(character, 4)
+	if migrated.BaseAbilities != character.BaseAbilities || migrated.RacialBonuses != (models.AbilityScores{}) {
+		t.Errorf("v4 changed existing base scores: %+v, racial %+v", migrated.BaseAbilities, migrated.RacialBonuses)
+	}
+}
+
+func TestMigrateCharacter(t *testing.T) {
+	migrated, result, err := MigrateCharacter(unversionedElf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.SchemaVersion != 4 || result.FromVersion != 0 || result.ToVersion != 4 || len(result.Applied) != 4 {
+		t.Errorf("migrated to %d; result %d -> %d with %d migrations", migrated.SchemaVersion, result.FromVersion, result.ToVersion, len(result.Applied))
+	}
+	if len(result.Changes) == 0 {
+		t.Error("no changes reported")
+	}
+
+	again, result, err := MigrateCharacter(migrated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != 0 || len(result.Changes) != 0 || result.ToVersion != 4 {
+		t.Errorf("migrating a current character: %+v", result)
+	}
+	if again.Race != migrated.Race || again.BaseAbilities != migrated.BaseAbilities {
+		t.Error("migrating a current character changed it")
+	}
+}
+
+// writeUnversioned writes characters to the characters file as an older
+// version would have.
+func writeUnversioned(t *testing.T, characters ...models.Character) {
+	t.Helper()
+	stored := map[string]models.Character{}
+	for _, character := range characters {
+		stored[character.Name] = character
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(CharactersFilePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanMigrationsDoesNotWrite(t *testing.T) {
+	useTempStorage(t)
+	writeUnversioned(t, unversionedElf())
+	before, err := os.ReadFile(CharactersFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := PlanMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "Elrond" || results[0].ToVersion != 4 {
+		t.Errorf("plan %+v", results)
+	}
+	after, err := os.ReadFile(CharactersFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("planning migrations changed the characters file")
+	}
+}
+
+func TestLoadCharactersMigratesInMemory(t *testing.T) {
+	useTempStorage(t)
+	second := unversionedElf()
+	second.Name = "Galadriel"
+	writeUnversioned(t, unversionedElf(), second)
+
+	characters, err := LoadCharacters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if characters["Elrond"].SchemaVersion != 4 || characters["Elrond"].Race != "elf" {
+		t.Errorf("loaded %+v", characters["Elrond"])
+	}
+	if characters["Elrond"].ID == characters["Galadriel"].ID {
+		t.Errorf("both characters have ID %d", characters["Elrond"].ID)
+	}
+
+	// Saving one character upgrades only that one in the file.
+	if err := SaveCharacter(characters["Galadriel"]); err != nil {
+		t.Fatal(err)
+	}
+	results, err := PlanMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "Elrond" {
+		t.Errorf("plan after loading and saving Galadriel: %+v", results)
+	}
+
+	results, err = MigrateAll()
+	if err != nil || len(results) != 1 {
+		t.Fatalf("MigrateAll: %+v, %v", results, err)
+	}
+	if results, err = PlanMigrations(); err != nil || len(results) != 0 {
+		t.Errorf("plan after MigrateAll: %+v, %v", results, err)
+	}
+}
@@ -4,24 +4,84 @@ import (
 	"dnd-character-sheet/models"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 )
 
 var CharactersFilePath = "characters.json"
 
+// Configure stores characters in dataFile and keeps the change history and
+// user accounts in the same directory.
+func Configure(dataFile string) {
+	CharactersFilePath = dataFile
+	HistoryFilePath = filepath.Join(filepath.Dir(dataFile), filepath.Base(HistoryFilePath))
+	UsersFilePath = filepath.Join(filepath.Dir(dataFile), filepath.Base(UsersFilePath))
+}
+
+// mu is held while the characters file is read, changed and written back, so
+// concurrent changes don't overwrite each other.
+var mu sync.Mutex
+
+var (
+	ErrCharacterNotFound = errors.New("character not found")
+	ErrCharacterExists   = errors.New("character already exists")
+)
+
 func SaveCharacter(character models.Character) error {
-	allCharacters, _ := LoadCharacters()
-	allCharacters[character.Name] = character
+	mu.Lock()
+	defer mu.Unlock()
 
-	data, err := json.MarshalIndent(allCharacters, "", "  ")
+	allCharacters, err := loadStoredCharacters()
 	if err != nil {
 		return err
 	}
+	character.SchemaVersion = CurrentSchemaVersion()
+	allCharacters[character.Name] = character
 
-	return os.WriteFile(CharactersFilePath, data, 0644)
+	return saveAllCharacters(allCharacters)
 }
 
+// LoadCharacters reads the characters file and upgrades characters stored
+// with an older schema version in memory. Only MigrateAll writes the upgrades
+// back.
 func LoadCharacters() (map[string]models.Character, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return loadCharacters()
+}
+
+func loadCharacters() (map[string]models.Character, error) {
+	characters, err := loadStoredCharacters()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := migrateCharacters(characters); err != nil {
+		return nil, err
+	}
+	return characters, nil
+}
+
+// loadStoredCharacters reads the characters file without upgrading it. IDs
+// given to characters without a unique one are saved right away, so they
+// don't change between loads.
+func loadStoredCharacters() (map[string]models.Character, error) {
+	characters, err := loadCharactersFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if assignUniqueIDs(characters) {
+		if err := saveAllCharacters(characters); err != nil {
+			return nil, err
+		}
+	}
+	return characters, nil
+}
+
+func loadCharactersFile() (map[string]models.Character, error) {
 	characters := make(map[string]models.Character)
 
 	if _, err := os.Stat(CharactersFilePath); errors.Is(err, os.ErrNotExist) {
@@ -40,7 +100,25 @@ func LoadCharacters() (map[string]models.Character, error) {
 	return characters, nil
 }
 
+// SaveAllCharacters replaces the characters file with allCharacters, stamped
+// with the current schema version.
 func SaveAllCharacters(allCharacters map[string]models.Character) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	version := CurrentSchemaVersion()
+	for name, character := range allCharacters {
+		if character.SchemaVersion != version {
+			character.SchemaVersion = version
+			allCharacters[name] = character
+		}
+	}
+	return saveAllCharacters(allCharacters)
+}
+
+// saveAllCharacters writes the characters as they are, so characters that
+// weren't changed keep their stored schema version.
+func saveAllCharacters(allCharacters map[string]models.Character) error {
 	fileData, err := json.MarshalIndent(allCharacters, "", "  ")
 	if err != nil {
 		return err
@@ -66,17 +144,30 @@ func GetNextCharacterID() (int, error) {
 }
 
 func DeleteCharacter(characterName string) error {
-	allCharacters, err := LoadCharacters()
+	mu.Lock()
+	defer mu.Unlock()
+
+	allCharacters, err := loadStoredCharacters()
 	if err != nil {
 		return err
 	}
 
-	if _, exists := allCharacters[characterName]; !exists {
-		return errors.New("character not found")
+	character, exists := allCharacters[characterName]
+	if !exists {
+		return ErrCharacterNotFound
 	}
 
 	delete(allCharacters, characterName)
-	return SaveAllCharacters(allCharacters)
+	if err := saveAllCharacters(allCharacters); err != nil {
+		return err
+	}
+	_, err = appendHistory(HistoryEntry{
+		CharacterID: character.ID,
+		Character:   character.Name,
+		Command:     "delete",
+		Deleted:     true,
+	})
+	return err
 }
 
 func GetCharacterByName(characterName string) (models.Character, error) {
@@ -87,8 +178,78 @@ func GetCharacterByName(characterName string) (models.Character, error) {
 
 	character, exists := allCharacters[characterName]
 	if !exists {
-		return models.Character{}, errors.New("character not found")
+		return models.Character{}, ErrCharacterNotFound
 	}
 
 	return character, nil
 }
+
+func GetCharacterByID(id int) (models.Character, error) {
+	allCharacters, err := LoadCharacters()
+	if err != nil {
+		return models.Character{}, err
+	}
+
+	for _, character := range allCharacters {
+		if character.ID == id {
+			return character, nil
+		}
+	}
+
+	return models.Character{}, ErrCharacterNotFound
+}
+
+// ReplaceCharacter stores character in place of the character saved under
+// previousName, which allows renaming.
+func ReplaceCharacter(previousName string, character models.Character) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	allCharacters, err := loadStoredCharacters()
+	if err != nil {
+		return err
+	}
+	return replaceCharacter(allCharacters, previousName, character)
+}
+
+func replaceCharacter(allCharacters map[string]models.Character, previousName string, character models.Character) error {
+	if _, exists := allCharacters[previousName]; !exists {
+		return ErrCharacterNotFound
+	}
+	if character.Name != previousName {
+		if _, taken := allCharacters[character.Name]; taken {
+			return fmt.Errorf("%w: %s", ErrCharacterExists, character.Name)
+		}
+		delete(allCharacters, previousName)
+	}
+
+	character.SchemaVersion = CurrentSchemaVersion()
+	allCharacters[character.Name] = character
+	return saveAllCharacters(allCharacters)
+}
+
+// assignUniqueIDs gives characters without an ID, or sharing one with another
+// character, a fresh ID. Characters are visited by name so the result is
+// stable. It reports whether any ID changed.
+func assignUniqueIDs(characters map[string]models.Character) bool {
+	highestID := 0
+	for _, character := range characters {
+		if character.ID > highestID {
+			highestID = character.ID
+		}
+	}
+
+	seen := map[int]bool{}
+	changed := false
+	for _, name := range sortedNames(characters) {
+		character := characters[name]
+		if character.ID <= 0 || seen[character.ID] {
+			highestID++
+			character.ID = highestID
+			characters[name] = character
+			changed = true
+		}
+		seen[character.ID] = true
+	}
+	return changed
+}
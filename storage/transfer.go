@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"dnd-character-sheet/models"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// WriteCharacterFile writes a single character to its own JSON file, stamped
+// with the current schema version.
+func WriteCharacterFile(filePath string, character models.Character) error {
+	character.SchemaVersion = CurrentSchemaVersion()
+
+	data, err := json.MarshalIndent(character, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// ReadCharacterFile reads a single exported character and upgrades it to the
+// current schema version.
+func ReadCharacterFile(filePath string) (models.Character, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return models.Character{}, err
+	}
+
+	var character models.Character
+	if err := json.Unmarshal(data, &character); err != nil {
+		return models.Character{}, fmt.Errorf("invalid character file: %w", err)
+	}
+
+	if character.SchemaVersion > CurrentSchemaVersion() {
+		return models.Character{}, fmt.Errorf("character file has schema version %d, newer than supported version %d",
+			character.SchemaVersion, CurrentSchemaVersion())
+	}
+
+	migrated, _, err := MigrateCharacter(character)
+	if err != nil {
+		return models.Character{}, err
+	}
+	return migrated, nil
+}
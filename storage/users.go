@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"dnd-character-sheet/models"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+)
+
+var UsersFilePath = "users.json"
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+)
+
+// LoadUsers returns the accounts keyed by lowercase username. A missing file
+// means there are no accounts yet.
+func LoadUsers() (map[string]models.User, error) {
+	users := map[string]models.User{}
+	data, err := os.ReadFile(UsersFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SaveUsers writes the accounts readable by the owner only, as the file holds
+// password hashes.
+func SaveUsers(users map[string]models.User) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(UsersFilePath, data, 0600)
+}
+
+func GetUser(username string) (models.User, error) {
+	users, err := LoadUsers()
+	if err != nil {
+		return models.User{}, err
+	}
+	user, ok := users[strings.ToLower(username)]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
+}